@@ -0,0 +1,156 @@
+package xmpush
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// 广播消息 (SendToAll, SendToTopic, SendToTopics) 的设备过滤条件
+
+var (
+	appVersionPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)*$`)
+	localePattern     = regexp.MustCompile(`^[a-z]{2,3}(_[A-Z]{2})?$`)
+)
+
+const connptWifi = "wifi"
+
+// 只发送给指定型号的设备
+func (m *Message) SetModels(models ...string) *Message {
+	m.Model = strings.Join(models, ",")
+	return m
+}
+
+// 只发送给指定 app 版本的设备
+func (m *Message) SetAppVersions(versions ...string) *Message {
+	m.AppVersion = strings.Join(versions, ",")
+	return m
+}
+
+// 不发送给指定 app 版本的设备
+func (m *Message) SetAppVersionsNotIn(versions ...string) *Message {
+	m.AppVersionNotIn = strings.Join(versions, ",")
+	return m
+}
+
+// 只发送给指定地区的设备, 格式如 zh_CN
+func (m *Message) SetLocales(locales ...string) *Message {
+	m.Locale = strings.Join(locales, ",")
+	return m
+}
+
+// 不发送给指定地区的设备, 格式如 zh_CN
+func (m *Message) SetLocalesNotIn(locales ...string) *Message {
+	m.LocaleNotIn = strings.Join(locales, ",")
+	return m
+}
+
+// 只在 wifi 网络下发送
+func (m *Message) EnableWifiOnly() *Message {
+	m.Connpt = connptWifi
+	return m
+}
+
+func (m *Message) DisableWifiOnly() *Message {
+	m.Connpt = ""
+	return m
+}
+
+func (m *Message) hasAudienceFilter() bool {
+	return m.Model != "" ||
+		m.AppVersion != "" ||
+		m.AppVersionNotIn != "" ||
+		m.Locale != "" ||
+		m.LocaleNotIn != "" ||
+		m.Connpt != ""
+}
+
+func validateAudienceFilter(m *Message) error {
+	if _, err := splitFilter("model", m.Model, nil); err != nil {
+		return err
+	}
+
+	versions, err := splitFilter("app_version", m.AppVersion, appVersionPattern)
+	if err != nil {
+		return err
+	}
+
+	versionsNotIn, err := splitFilter("app_version_not_in", m.AppVersionNotIn, appVersionPattern)
+	if err != nil {
+		return err
+	}
+
+	if v := intersect(versions, versionsNotIn); v != "" {
+		return fmt.Errorf("app version %s in both app_version and app_version_not_in", v)
+	}
+
+	locales, err := splitFilter("locale", m.Locale, localePattern)
+	if err != nil {
+		return err
+	}
+
+	localesNotIn, err := splitFilter("locale_not_in", m.LocaleNotIn, localePattern)
+	if err != nil {
+		return err
+	}
+
+	if v := intersect(locales, localesNotIn); v != "" {
+		return fmt.Errorf("locale %s in both locale and locale_not_in", v)
+	}
+
+	if m.Connpt != "" && m.Connpt != connptWifi {
+		return fmt.Errorf("unknown connpt %s", m.Connpt)
+	}
+
+	return nil
+}
+
+func splitFilter(name string, value string, pattern *regexp.Regexp) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	items := strings.Split(value, ",")
+	for _, item := range items {
+		if strings.TrimSpace(item) == "" {
+			return nil, fmt.Errorf("%s has empty item", name)
+		}
+		if pattern != nil && !pattern.MatchString(item) {
+			return nil, fmt.Errorf("invalid %s %s", name, item)
+		}
+	}
+	return items, nil
+}
+
+func intersect(a, b []string) string {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return x
+			}
+		}
+	}
+	return ""
+}
+
+func addAudienceFilter(form *url.Values, m *Message) {
+	if m.Model != "" {
+		form.Add("model", m.Model)
+	}
+	if m.AppVersion != "" {
+		form.Add("app_version", m.AppVersion)
+	}
+	if m.AppVersionNotIn != "" {
+		form.Add("app_version_not_in", m.AppVersionNotIn)
+	}
+	if m.Locale != "" {
+		form.Add("locale", m.Locale)
+	}
+	if m.LocaleNotIn != "" {
+		form.Add("locale_not_in", m.LocaleNotIn)
+	}
+	if m.Connpt != "" {
+		form.Add("connpt", m.Connpt)
+	}
+}
@@ -35,6 +35,12 @@ const (
 
 	apiRetryTimes = 3
 
+	maxRegIdsPerSend  = 1000
+	maxPresenceRegIds = 1000
+
+	// 在线状态查询使用 GET, 每个请求最多的 regId 数, 避免 URL 过长
+	presenceRegIdsPerRequest = 100
+
 	regIdURL   = "/v3/message/regid"
 	aliasURL   = "/v3/message/alias"
 	accountURL = "/v2/message/user_account"
@@ -63,6 +69,15 @@ const (
 
 	scheduleJobExistURL  = "/v2/schedule_job/exist"
 	scheduleJobDeleteURL = "/v2/schedule_job/delete"
+
+	scheduleJobExistByJobKeyURL  = "/v3/schedule_job/exist"
+	scheduleJobDeleteByJobKeyURL = "/v3/schedule_job/delete"
+
+	regIdPresenceURL  = "/v1/regid/presence"
+	regIdsPresenceURL = "/v2/regid/presence"
+
+	recallURL      = "/v1/message/recall"
+	recallTopicURL = "/v1/message/topic/recall"
 )
 
 // 创建客户端
@@ -78,7 +93,8 @@ func NewClient(appSecret string, packageNames ...string) (*Client, error) {
 		client: &http.Client{
 			Timeout: 20 * time.Second,
 		},
-		log: &nopeLogger{},
+		log:       &nopeLogger{},
+		schedules: newScheduleRegistry(),
 	}, nil
 }
 
@@ -89,8 +105,17 @@ type Client struct {
 	hasMultiPackageName bool
 	client              *http.Client
 	log                 logger
+	idempotent          bool
+	schedules           *scheduleRegistry
+	invalidRegIdHandler InvalidRegIdHandler
+	truncate            bool
+	interceptors        []SendInterceptor
+	contentChecker      ContentChecker
 }
 
+// 处理发送结果中失效的 regId
+type InvalidRegIdHandler func(badTargets []BadTarget)
+
 func (c *Client) UseSandbox(use bool) {
 	c.useSandbox = use
 }
@@ -99,90 +124,95 @@ func (c *Client) SetLogger(logger logger) {
 	c.log = logger
 }
 
-// 向 regId 发送单条消息
-func (c *Client) SendToRegId(message *Message, regId *[]string) (*SendResult, error) {
-	param, err := c.buildParam(message, "registration_id", regId)
-	if err != nil {
-		return nil, err
-	}
-
-	res, err := c.doPost(regIdURL, param)
-	if err != nil {
-		return nil, err
-	}
+// 启用后, 没有设置 jobKey 的消息在发送时会自动生成 jobKey,
+// 相同 jobKey 的消息小米服务端只会推送一次, 请求失败重试前会先通过 jobKey
+// 查询消息是否已经发送成功, 避免重复推送
+func (c *Client) SetIdempotent(enable bool) {
+	c.idempotent = enable
+}
 
-	var result SendResult
-	err = json.Unmarshal(res, &result)
-	if err != nil {
-		return nil, err
-	}
+// 设置失效 regId 的处理函数, 每次发送后如果返回结果中有失效的 regId 会被调用,
+// 可用于清理失效的 regId
+func (c *Client) SetInvalidRegIdHandler(handler InvalidRegIdHandler) {
+	c.invalidRegIdHandler = handler
+}
 
-	return &result, nil
+// 启用后, 超过长度限制的标题和描述在发送时会被截断并以省略号结尾,
+// 只在用户看到的字符之间截断, 不会拆分 emoji 等组合字符
+func (c *Client) SetTruncate(enable bool) {
+	c.truncate = enable
 }
 
-// 向 alias 发送单条消息
-func (c *Client) SendToAlias(message *Message, alias *[]string) (*SendResult, error) {
-	param, err := c.buildParam(message, "alias", alias)
-	if err != nil {
-		return nil, err
+// 设置发送请求使用的 http.Client, 可用于多个 client 共享连接
+func (c *Client) SetHTTPClient(client *http.Client) {
+	if client != nil {
+		c.client = client
 	}
+}
 
-	res, err := c.doPost(aliasURL, param)
-	if err != nil {
-		return nil, err
+// 返回只作用于指定 package 的 client，与原 client 共享配置
+//
+// packageName 必须是创建 client 时传入的 package 之一
+func (c *Client) ForPackage(packageName string) (*Client, error) {
+	if !c.hasPackageName(packageName) {
+		return nil, fmt.Errorf("unknown package name %s", packageName)
 	}
 
-	var result SendResult
-	err = json.Unmarshal(res, &result)
-	if err != nil {
-		return nil, err
-	}
+	nc := *c
+	nc.packageNames = []string{packageName}
+	nc.hasMultiPackageName = false
+	return &nc, nil
+}
 
-	return &result, nil
+// client 当前配置的所有 package
+func (c *Client) PackageNames() []string {
+	names := make([]string, len(c.packageNames))
+	copy(names, c.packageNames)
+	return names
 }
 
-// 向 account 发送单条消息
-func (c *Client) SendToAccount(message *Message, account *[]string) (*SendResult, error) {
-	param, err := c.buildParam(message, "user_account", account)
-	if err != nil {
-		return nil, err
+func (c *Client) hasPackageName(packageName string) bool {
+	for _, name := range c.packageNames {
+		if name == packageName {
+			return true
+		}
 	}
+	return false
+}
 
-	res, err := c.doPost(accountURL, param)
-	if err != nil {
-		return nil, err
+// 消息设置了 RestrictedPackageName 时使用消息的设置，否则使用 client 的所有 package
+func (c *Client) restrictedPackageName(message *Message) (string, error) {
+	if message == nil || message.RestrictedPackageName == "" {
+		return strings.Join(c.packageNames, ","), nil
 	}
 
-	var result SendResult
-	err = json.Unmarshal(res, &result)
-	if err != nil {
-		return nil, err
+	for _, name := range strings.Split(message.RestrictedPackageName, ",") {
+		if !c.hasPackageName(name) {
+			return "", fmt.Errorf("restricted package name %s not in client", name)
+		}
 	}
 
-	return &result, nil
+	return message.RestrictedPackageName, nil
 }
 
-// 向 topic 发送单条消息
-func (c *Client) SendToTopic(message *Message, topic string) (*SendResult, error) {
-	param, err := c.messageToForm(message)
-	if err != nil {
-		return nil, err
-	}
-
-	param.Add("topic", topic)
+// 向 regId 发送单条消息
+func (c *Client) SendToRegId(message *Message, regId *[]string) (*SendResult, error) {
+	return c.dispatch(&SendRequest{Target: RegIdTarget(stringValues(regId)...), Message: message})
+}
 
-	res, err := c.doPost(TopicURL, param)
-	if err != nil {
-		return nil, err
-	}
+// 向 alias 发送单条消息
+func (c *Client) SendToAlias(message *Message, alias *[]string) (*SendResult, error) {
+	return c.dispatch(&SendRequest{Target: AliasTarget(stringValues(alias)...), Message: message})
+}
 
-	var result SendResult
-	err = json.Unmarshal(res, &result)
-	if err != nil {
-		return nil, err
-	}
+// 向 account 发送单条消息
+func (c *Client) SendToAccount(message *Message, account *[]string) (*SendResult, error) {
+	return c.dispatch(&SendRequest{Target: AccountTarget(stringValues(account)...), Message: message})
+}
 
-	return &result, nil
+// 向 topic 发送单条消息
+func (c *Client) SendToTopic(message *Message, topic string) (*SendResult, error) {
+	return c.dispatch(&SendRequest{Target: TopicTarget(topic), Message: message})
 }
 
 type TopicOP string
@@ -197,47 +227,11 @@ const (
 //
 // topics 为 2 ~ 5 个， topicOP 为空时，默认为取并集
 func (c *Client) SendToTopics(message *Message, topics *[]string, topicOP TopicOP) (*SendResult, error) {
-	param, err := c.messageToForm(message)
-	if err != nil {
-		return nil, err
-	}
-
 	if topics == nil {
 		return nil, errors.New("topics should not nil")
 	}
 
-	ts := *topics
-	tc := len(ts)
-
-	if tc < 2 || tc > 5 {
-		return nil, errors.New("topics count should between 1 and 5")
-	}
-
-	if topicOP == "" {
-		topicOP = TopicOPUnion
-	}
-
-	if topicOP != TopicOPUnion &&
-		topicOP != TopicOPIntersection &&
-		topicOP != TopicOPExcept {
-		return nil, errors.New("unknown topic option")
-	}
-
-	param.Add("topics", strings.Join(ts, ";$;"))
-	param.Add("topic_op", string(topicOP))
-
-	res, err := c.doPost(TopicOpURL, param)
-	if err != nil {
-		return nil, err
-	}
-
-	var result SendResult
-	err = json.Unmarshal(res, &result)
-	if err != nil {
-		return nil, err
-	}
-
-	return &result, nil
+	return c.dispatch(&SendRequest{Target: TopicsTarget(topicOP, *topics...), Message: message})
 }
 
 // 推送多条消息 (regId, alias, account) 通过 targetType 判断
@@ -247,66 +241,78 @@ func (c *Client) SendTargetedMessage(messages *[]TargetedMessage) (*SendResult,
 		return nil, errors.New("messages can't empty")
 	}
 
-	param, err := c.buildTargetedMessageParam(messages)
-	if err != nil {
-		return nil, err
-	}
-
-	var apiURI string
-	targetType := (*messages)[0].targetType
-	switch targetType {
-	case TargetRegId:
-		apiURI = multiRegIdURL
-	case TargetAlias:
-		apiURI = multiAliasURL
-	case TargetAccount:
-		apiURI = multiAccountURL
-	default:
-		return nil, fmt.Errorf("unknown target type %d", targetType)
-	}
-
-	res, err := c.doPost(apiURI, param)
-	if err != nil {
-		return nil, err
-	}
-
-	var result SendResult
-	err = json.Unmarshal(res, &result)
-	if err != nil {
-		return nil, err
-	}
-
-	return &result, nil
+	return c.dispatch(&SendRequest{
+		Target:   Target{Type: (*messages)[0].targetType},
+		Messages: *messages,
+	})
 }
 
 // 向 所有设备 发送单条消息
 func (c *Client) SendToAll(message *Message) (*SendResult, error) {
-	param, err := c.messageToForm(message)
+	return c.dispatch(&SendRequest{Target: AllTarget(), Message: message})
+}
+
+// 按 target 类型发送单条消息
+func (c *Client) Send(message *Message, target Target) (*SendResult, error) {
+	return c.dispatch(&SendRequest{Target: target, Message: message})
+}
+
+// 获取消息的统计数据
+// start, end 格式为： yyyyMMdd
+//
+// client 有多个 package 时，按日期汇总所有 package 的数据，
+// 需要单个 package 的数据时使用 ForPackage 或 StatsByPackage
+func (c *Client) Stats(start, end string) (*StatsResult, error) {
+	results, err := c.StatsByPackage(start, end)
 	if err != nil {
 		return nil, err
 	}
 
-	res, err := c.doPost(allURL, param)
-	if err != nil {
-		return nil, err
+	if len(c.packageNames) == 1 {
+		return results[c.packageNames[0]], nil
 	}
 
-	var result SendResult
-	err = json.Unmarshal(res, &result)
-	if err != nil {
-		return nil, err
+	var result StatsResult
+	index := make(map[string]int)
+	for _, packageName := range c.packageNames {
+		r := results[packageName]
+		if result.Result.Result == "" || r.Code != 0 {
+			result.Result = r.Result
+		}
+
+		for _, stat := range r.Data.Data {
+			i, ok := index[stat.Date]
+			if !ok {
+				index[stat.Date] = len(result.Data.Data)
+				result.Data.Data = append(result.Data.Data, stat)
+				continue
+			}
+			result.Data.Data[i].add(&stat)
+		}
 	}
 
 	return &result, nil
 }
 
-// 获取消息的统计数据
+// 分别获取每个 package 的统计数据, 以 packageName 为 key
 // start, end 格式为： yyyyMMdd
-func (c *Client) Stats(start, end string) (*StatsResult, error) {
+func (c *Client) StatsByPackage(start, end string) (map[string]*StatsResult, error) {
+	results := make(map[string]*StatsResult, len(c.packageNames))
+	for _, packageName := range c.packageNames {
+		result, err := c.stats(packageName, start, end)
+		if err != nil {
+			return nil, err
+		}
+		results[packageName] = result
+	}
+	return results, nil
+}
+
+func (c *Client) stats(packageName, start, end string) (*StatsResult, error) {
 	form := &url.Values{}
 	form.Add("start_date", start)
 	form.Add("end_date", end)
-	form.Add("restricted_package_name", c.packageNames[0])
+	form.Add("restricted_package_name", packageName)
 
 	res, err := c.doGet(statsURL, form)
 	if err != nil {
@@ -506,8 +512,118 @@ func (c *Client) GetRegIdTopic(regId string) (*RegIdTopicResult, error) {
 	return &result, nil
 }
 
+// 查询 regId 的在线状态
+func (c *Client) GetRegIdPresence(regId string) (*PresenceResult, error) {
+	if regId == "" {
+		return nil, errors.New("regId can't empty")
+	}
+
+	form := &url.Values{}
+	form.Add("registration_id", regId)
+	if c.hasMultiPackageName {
+		form.Add("restricted_package_name", strings.Join(c.packageNames, ","))
+	}
+
+	res, err := c.doGet(regIdPresenceURL, form)
+	if err != nil {
+		return nil, err
+	}
+
+	var result PresenceResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// 批量查询 regId 的在线状态, 一次最多 1000 个
+//
+// 接口使用 GET, 每 100 个 regId 一个请求, 合并后返回
+func (c *Client) GetRegIdsPresence(regIds *[]string) (*BatchPresenceResult, error) {
+	if regIds == nil || len(*regIds) == 0 || len(*regIds) > maxPresenceRegIds {
+		return nil, errors.New("count should more than 1 and less than 1000")
+	}
+
+	var result *BatchPresenceResult
+	for start := 0; start < len(*regIds); start += presenceRegIdsPerRequest {
+		end := start + presenceRegIdsPerRequest
+		if end > len(*regIds) {
+			end = len(*regIds)
+		}
+
+		batch, err := c.getRegIdsPresence((*regIds)[start:end])
+		if err != nil {
+			return nil, err
+		}
+
+		// 出错时返回出错请求的结果
+		if batch.Code != 0 {
+			return batch, nil
+		}
+
+		if result == nil {
+			result = batch
+		} else {
+			result.Data = append(result.Data, batch.Data...)
+		}
+	}
+
+	return result, nil
+}
+
+func (c *Client) getRegIdsPresence(regIds []string) (*BatchPresenceResult, error) {
+	form := &url.Values{}
+	form.Add("registration_id", strings.Join(regIds, ","))
+	if c.hasMultiPackageName {
+		form.Add("restricted_package_name", strings.Join(c.packageNames, ","))
+	}
+
+	res, err := c.doGet(regIdsPresenceURL, form)
+	if err != nil {
+		return nil, err
+	}
+
+	var result BatchPresenceResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// 过滤出在线的 regId, 可在大批量发送前去除失效的 regId
+func (c *Client) FilterPresentRegIds(regIds []string) ([]string, error) {
+	var present []string
+	for start := 0; start < len(regIds); start += maxPresenceRegIds {
+		end := start + maxPresenceRegIds
+		if end > len(regIds) {
+			end = len(regIds)
+		}
+
+		batch := regIds[start:end]
+		result, err := c.GetRegIdsPresence(&batch)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := apiError(&result.Result); err != nil {
+			return nil, err
+		}
+
+		for _, p := range result.Data {
+			if p.Presence {
+				present = append(present, p.RegId)
+			}
+		}
+	}
+	return present, nil
+}
+
 // 定时任务是否存在
-// result.code = 0 为任务存在, 否则不存在
+// result.code = 0 为任务存在, 否则不存在, 也可以使用 Schedule 返回的 ScheduledJob
 func (c *Client) ScheduleJobExist(messageId string) (*Result, error) {
 	form := &url.Values{}
 	form.Add("job_id", messageId)
@@ -545,6 +661,141 @@ func (c *Client) ScheduleJobDelete(messageId string) (*Result, error) {
 	return &result, nil
 }
 
+// 撤回已发送的消息 (regId, alias, account)
+func (c *Client) RecallMessage(messageId string) (*RecallResult, error) {
+	if messageId == "" {
+		return nil, errors.New("message id can't empty")
+	}
+
+	form := &url.Values{}
+	form.Add("msg_id", messageId)
+
+	return c.recall(recallURL, form)
+}
+
+// 撤回已发送的 topic 消息
+func (c *Client) RecallTopicMessage(messageId string, topic string) (*RecallResult, error) {
+	if messageId == "" || topic == "" {
+		return nil, errors.New("message id and topic can't empty")
+	}
+
+	form := &url.Values{}
+	form.Add("msg_id", messageId)
+	form.Add("topic", topic)
+
+	return c.recall(recallTopicURL, form)
+}
+
+func (c *Client) recall(api string, form *url.Values) (*RecallResult, error) {
+	if c.hasMultiPackageName {
+		form.Add("restricted_package_name", strings.Join(c.packageNames, ","))
+	}
+
+	res, err := c.doPost(api, form)
+	if err != nil {
+		return nil, err
+	}
+
+	var result RecallResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// 定时任务是否存在 - jobKey
+// result.code = 0 为任务存在, 否则不存在
+func (c *Client) ScheduleJobExistByJobKey(jobKey string) (*Result, error) {
+	if jobKey == "" {
+		return nil, errors.New("jobKey can't empty")
+	}
+
+	form := &url.Values{}
+	form.Add("jobkey", jobKey)
+
+	res, err := c.doPost(scheduleJobExistByJobKeyURL, form)
+	if err != nil {
+		return nil, err
+	}
+
+	var result Result
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// 删除定时任务 - jobKey
+func (c *Client) ScheduleJobDeleteByJobKey(jobKey string) (*Result, error) {
+	if jobKey == "" {
+		return nil, errors.New("jobKey can't empty")
+	}
+
+	form := &url.Values{}
+	form.Add("jobkey", jobKey)
+
+	res, err := c.doPost(scheduleJobDeleteByJobKeyURL, form)
+	if err != nil {
+		return nil, err
+	}
+
+	var result Result
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Code == 0 {
+		c.schedules.removeJobKey(jobKey)
+	}
+
+	return &result, nil
+}
+
+// 批量删除定时任务 - jobKey, 返回以 jobKey 为 key 的结果
+//
+// 发生错误时停止删除, 返回已完成的结果和错误
+func (c *Client) ScheduleJobsDeleteByJobKeys(jobKeys []string) (map[string]*Result, error) {
+	results := make(map[string]*Result, len(jobKeys))
+	for _, jobKey := range jobKeys {
+		result, err := c.ScheduleJobDeleteByJobKey(jobKey)
+		if err != nil {
+			return results, fmt.Errorf("jobKey %s: %v", jobKey, err)
+		}
+		results[jobKey] = result
+	}
+	return results, nil
+}
+
+func (c *Client) postSend(api string, param *url.Values) (*SendResult, error) {
+	res, err := c.doPost(api, param)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.decodeSendResult(res)
+}
+
+func (c *Client) decodeSendResult(res []byte) (*SendResult, error) {
+	var result SendResult
+	err := json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.invalidRegIdHandler != nil {
+		if badTargets := result.BadTargets(); len(badTargets) > 0 {
+			c.invalidRegIdHandler(badTargets)
+		}
+	}
+
+	return &result, nil
+}
+
 func (c *Client) doPost(api string, form *url.Values) ([]byte, error) {
 	param := ""
 	if form != nil {
@@ -563,7 +814,14 @@ func (c *Client) doPost(api string, form *url.Values) ([]byte, error) {
 
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=UTF-8")
 
-	return c.doReq(req)
+	var guard retryGuard
+	if c.idempotent {
+		if jobKey := retryJobKey(form); jobKey != "" {
+			guard = c.jobKeyGuard(jobKey)
+		}
+	}
+
+	return c.doReq(req, guard)
 }
 
 func (c *Client) doGet(api string, form *url.Values) ([]byte, error) {
@@ -582,15 +840,65 @@ func (c *Client) doGet(api string, form *url.Values) ([]byte, error) {
 
 	c.log.Debugf("doGet request url: %v", req.URL)
 
-	return c.doReq(req)
+	return c.doReq(req, nil)
 }
 
-func (c *Client) doReq(req *http.Request) ([]byte, error) {
+// 请求失败后、重试之前调用, 包括网络错误、读取响应失败和非 200 的响应
+// 返回 true 时表示请求已经生效, 使用返回的 body 作为结果, 不再重试
+type retryGuard func() ([]byte, bool)
+
+// 重试前用于查询发送状态的 jobKey
+//
+// 单条消息使用 extra.jobkey, 多条消息 (SendTargetedMessage) 使用第一条消息的 jobkey,
+// 多条消息在同一个请求中发送, 第一条已发送说明整个请求已经生效
+func retryJobKey(form *url.Values) string {
+	if form == nil {
+		return ""
+	}
+	if jobKey := form.Get("extra.jobkey"); jobKey != "" {
+		return jobKey
+	}
+
+	messages := form.Get("messages")
+	if messages == "" {
+		return ""
+	}
+
+	var ms []struct {
+		Message struct {
+			Extra map[string]string `json:"extra"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(messages), &ms); err != nil || len(ms) == 0 {
+		return ""
+	}
+	return ms[0].Message.Extra["jobkey"]
+}
+
+func (c *Client) doReq(req *http.Request, guard retryGuard) ([]byte, error) {
 	req.Header.Add("Authorization", fmt.Sprintf("key=%s", c.appSecret))
 
 	var lastError error
 	var body []byte
 	for i := 0; i < apiRetryTimes; i += 1 {
+		if i > 0 {
+			// 网络错误、读取响应超时或服务端错误时, 请求可能已经被处理
+			if guard != nil {
+				if guardBody, done := guard(); done {
+					c.log.Debug("retry guard hit, response: ", string(guardBody))
+					return guardBody, nil
+				}
+			}
+
+			if req.GetBody != nil {
+				reqBody, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				req.Body = reqBody
+			}
+		}
+
 		lastError = nil
 		res, err := c.client.Do(req)
 		lastError = err
@@ -628,6 +936,26 @@ func (c *Client) doReq(req *http.Request) ([]byte, error) {
 	return body, lastError
 }
 
+// 通过 jobKey 查询消息是否已经发送成功
+func (c *Client) jobKeyGuard(jobKey string) retryGuard {
+	return func() ([]byte, bool) {
+		status, err := c.GetMessageStatusByJobKey(jobKey)
+		if err != nil || status.Code != 0 || status.Data.Data.ID == "" {
+			return nil, false
+		}
+
+		var result SendResult
+		result.Result = status.Result
+		result.Data.ID = status.Data.Data.ID
+
+		body, err := json.Marshal(&result)
+		if err != nil {
+			return nil, false
+		}
+		return body, true
+	}
+}
+
 func (c *Client) buildURI(uri string) string {
 	if strings.HasPrefix(uri, "http") {
 		return uri
@@ -643,50 +971,50 @@ func (c *Client) baseURI() string {
 }
 
 func (c *Client) validateMessage(message *Message) (bool, error) {
-	if message.Title == "" || message.Description == "" {
-		return false, errors.New("title and description can't empty")
+	if err := message.Validate(); err != nil {
+		return false, err
 	}
 
-	notifyType := message.NotifyType
-	if notifyType != -1 &&
-		notifyType != 1 &&
-		notifyType != 2 &&
-		notifyType != 4 {
-		return false, fmt.Errorf("unknown notifyType %d", notifyType)
-	}
+	return true, nil
+}
 
-	if message.TimeToLive > 0 {
-		t := time.Now().Add(time.Duration(message.TimeToLive) * time.Millisecond)
-		if t.After(time.Now().Add(time.Duration(MaxTimeToLive) * time.Millisecond)) {
-			return false, fmt.Errorf("TimeToLive too long (%s %v)", message.Title, message.TimeToLive)
-		}
+// 发送前复制消息, 之后的处理不受调用方修改的影响
+// 启用截断时截断标题和描述
+func (c *Client) snapshotMessage(message *Message) (*Message, error) {
+	if message == nil {
+		return nil, errors.New("message can't nil")
 	}
 
-	if message.TimeToSend > 0 {
-		t := time.Now().Add(MaxTimeToSend)
-		sc := time.Unix(0, message.TimeToSend*int64(time.Millisecond))
-		if t.Before(sc) {
-			return false, fmt.Errorf("TimeToSend error (%s %v), should before %v",
-				message.Title, message.TimeToSend, t.Format(time.RFC3339))
-		}
-
-		if sc.Before(time.Now()) {
-			return false, fmt.Errorf("TimeToSend error (%s %v), should after (now) %v",
-				message.Title, message.TimeToSend, time.Now().Format(time.RFC3339))
-		}
+	message = message.Clone()
+	if c.truncate {
+		message.Title = truncateText(message.Title, MaxTitleLength)
+		message.Description = truncateText(message.Description, MaxDescriptionLength)
 	}
-
-	return true, nil
+	return message, nil
 }
 
 func (c *Client) messageToForm(message *Message) (*url.Values, error) {
+	message, err := c.snapshotMessage(message)
+	if err != nil {
+		return nil, err
+	}
+
 	if _, err := c.validateMessage(message); err != nil {
 		return nil, err
 	}
 
+	if err := c.checkContent(message); err != nil {
+		return nil, err
+	}
+
+	packageName, err := c.restrictedPackageName(message)
+	if err != nil {
+		return nil, err
+	}
+
 	form := &url.Values{}
 
-	form.Add("restricted_package_name", strings.Join(c.packageNames, ","))
+	form.Add("restricted_package_name", packageName)
 	form.Add("payload", message.Payload)
 	form.Add("title", message.Title)
 	form.Add("description", message.Description)
@@ -711,10 +1039,24 @@ func (c *Client) messageToForm(message *Message) (*url.Values, error) {
 		}
 	}
 
+	addAudienceFilter(form, message)
+
+	if c.idempotent && form.Get("extra.jobkey") == "" {
+		jobKey, err := newJobKey()
+		if err != nil {
+			return nil, err
+		}
+		form.Set("extra.jobkey", jobKey)
+	}
+
 	return form, nil
 }
 
 func (c *Client) buildParam(message *Message, paramType string, item *[]string) (*url.Values, error) {
+	if message.hasAudienceFilter() {
+		return nil, errors.New("audience filter only support broadcast message")
+	}
+
 	form, err := c.messageToForm(message)
 	if err != nil {
 		return nil, err
@@ -727,7 +1069,7 @@ func (c *Client) buildParam(message *Message, paramType string, item *[]string)
 	}
 
 	count := len(*item)
-	if count == 0 || count > 1000 {
+	if count == 0 || count > maxRegIdsPerSend {
 		return nil, err
 	}
 
@@ -743,13 +1085,41 @@ func (c *Client) buildTargetedMessageParam(messages *[]TargetedMessage) (*url.Va
 
 	var ms []M
 	for _, m := range *messages {
-		if _, err := c.validateMessage(m.message); err != nil {
+		message, err := c.snapshotMessage(m.message)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := c.validateMessage(message); err != nil {
+			return nil, err
+		}
+
+		if err := c.checkContent(message); err != nil {
+			return nil, err
+		}
+
+		if message.hasAudienceFilter() {
+			return nil, errors.New("audience filter only support broadcast message")
+		}
+
+		packageName, err := c.restrictedPackageName(message)
+		if err != nil {
 			return nil, err
 		}
 
+		message.RestrictedPackageName = packageName
+
+		if c.idempotent && message.Extra["jobkey"] == "" {
+			jobKey, err := newJobKey()
+			if err != nil {
+				return nil, err
+			}
+			message.SetJobKey(jobKey)
+		}
+
 		ms = append(ms, M{
 			Target:  m.target,
-			Message: m.message,
+			Message: message,
 		})
 	}
 
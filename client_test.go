@@ -110,6 +110,40 @@ func TestClient_SendToRegId(t *testing.T) {
 	l.Debug(result.Info)
 }
 
+func TestClient_SetIdempotent(t *testing.T) {
+	c, err := NewClient(appSecret, packageName...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetLogger(l)
+	c.SetIdempotent(true)
+
+	m := NewMessage("idempotent", "description")
+	form, err := c.messageToForm(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if form.Get("extra.jobkey") == "" {
+		t.Fatal("jobkey should be generated")
+	}
+
+	if _, ok := m.Extra["jobkey"]; ok {
+		t.Fatal("message should not be modified")
+	}
+
+	result, err := c.SendToRegId(m, &regId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Code != 0 {
+		t.Fatal(result.Code, result.Description)
+	}
+
+	l.Debug(result.Data.ID)
+}
+
 func TestClient_SendToAlias(t *testing.T) {
 	result, err := client.SendToAlias(message, &alias)
 	if err != nil {
@@ -238,6 +272,48 @@ func TestClient_SendToAll(t *testing.T) {
 	l.Debug(result.Info)
 }
 
+func TestClient_SendToTopicWithAudienceFilter(t *testing.T) {
+	m := NewMessage("audience filter", "description").
+		SetAppVersions("1.0.0", "1.1.0").
+		SetLocalesNotIn("en_US").
+		EnableWifiOnly()
+
+	result, err := client.SendToTopic(m, topic[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Code != 0 {
+		t.Fatal(result.Code, result.Description)
+	}
+
+	if _, err := client.SendToRegId(m, &regId); err == nil {
+		t.Fatal("audience filter should reject regId message")
+	}
+
+	if _, err := client.SendToAll(NewMessage("t", "d").SetLocales("zh-cn")); err == nil {
+		t.Fatal("invalid locale should error")
+	}
+
+	l.Debug(result.Data.ID)
+}
+
+func TestClient_SendHybridToRegId(t *testing.T) {
+	m := NewHybridMessage("hybrid", "description", "com.example.quickapp").
+		SetHybridPath("/detail?id=1")
+
+	result, err := client.SendHybridToRegId(m, &regId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Code != 0 {
+		t.Fatal(result.Code, result.Description)
+	}
+
+	l.Debug(result.Data.ID)
+}
+
 func TestClient_Stats(t *testing.T) {
 	start := time.Now().AddDate(0, 0, -7)
 	end := time.Now()
@@ -257,6 +333,46 @@ func TestClient_Stats(t *testing.T) {
 	l.Debug(result.Info)
 }
 
+func TestClient_StatsByPackage(t *testing.T) {
+	start := time.Now().AddDate(0, 0, -7)
+	end := time.Now()
+	format := "20060102"
+	results, err := client.StatsByPackage(start.Format(format), end.Format(format))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for packageName, result := range results {
+		if result.Code != 0 {
+			t.Fatal(packageName, result.Code, result.Description)
+		}
+		l.Debug(packageName, " ", result.Data.Data)
+	}
+}
+
+func TestClient_ForPackage(t *testing.T) {
+	if _, err := client.ForPackage("com.unknown.package"); err == nil {
+		t.Fatal("unknown package should error")
+	}
+
+	c, err := client.ForPackage(packageName[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := c.SendToRegId(NewMessage("for package", "description"), &regId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Code != 0 {
+		t.Fatal(result.Code, result.Description)
+	}
+
+	l.Debug(result.Data.ID)
+}
+
 func TestClient_GetMessageStatusByMessageId(t *testing.T) {
 	result, err := client.GetMessageStatusByMessageId("scm55282525064870278fz")
 	if err != nil {
@@ -408,6 +524,30 @@ func TestClient_GetRegIdTopic(t *testing.T) {
 	l.Debug(result.Code, " ", result.Description)
 }
 
+func TestClient_GetRegIdPresence(t *testing.T) {
+	result, err := client.GetRegIdPresence(regId[0])
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Code != 0 {
+		t.Fatal(result.Code, result.Description)
+	}
+
+	l.Debug(result.Data.Presence, " ", result.Data.LastSeen())
+}
+
+func TestClient_FilterPresentRegIds(t *testing.T) {
+	present, err := client.FilterPresentRegIds(regId)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.Debug(present)
+}
+
 func TestClient_ScheduleJobExist(t *testing.T) {
 	result, err := client.ScheduleJobExist("scm55282525064870278fz")
 
@@ -427,3 +567,75 @@ func TestClient_ScheduleJobDelete(t *testing.T) {
 
 	l.Debug(result)
 }
+
+func TestClient_Schedule(t *testing.T) {
+	job, err := client.Schedule(NewMessage("schedule", "description"), RegIdTarget(regId...),
+		time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exists, err := job.Exists()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !exists {
+		t.Fatal("job should exist", job.ID)
+	}
+
+	if len(client.ScheduledJobs()) == 0 {
+		t.Fatal("job should be tracked")
+	}
+
+	if err := job.Reschedule(time.Now().Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := job.Cancel(); err != nil {
+		t.Fatal(err)
+	}
+
+	l.Debug(job.ID)
+}
+
+func TestClient_RecallMessage(t *testing.T) {
+	sent, err := client.SendToRegId(NewMessage("recall", "description"), &regId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := client.RecallMessage(sent.Data.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.Recalled() {
+		t.Fatal(result.Code, result.Description)
+	}
+
+	l.Debug(result)
+}
+
+func TestClient_ScheduleJobsDeleteByJobKeys(t *testing.T) {
+	m := NewMessage("schedule job key", "description").SetJobKey("job_schedule_1")
+	job, err := client.Schedule(m, RegIdTarget(regId...), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exist, err := client.ScheduleJobExistByJobKey("job_schedule_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Debug(job.ID, " ", exist)
+
+	results, err := client.ScheduleJobsDeleteByJobKeys([]string{"job_schedule_1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results["job_schedule_1"].Code != 0 {
+		t.Fatal(results["job_schedule_1"].Code, results["job_schedule_1"].Description)
+	}
+}
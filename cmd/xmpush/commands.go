@@ -0,0 +1,251 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xinpianchang/xmpush"
+)
+
+// 可重复的 key=value 参数
+type extraFlag map[string]string
+
+func (f extraFlag) String() string {
+	var pairs []string
+	for k, v := range f {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f extraFlag) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return fmt.Errorf("extra should be key=value, got %s", s)
+	}
+	f[s[:i]] = s[i+1:]
+	return nil
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("xmpush "+name, flag.ExitOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
+
+func runSend(client *xmpush.Client, args []string) (interface{}, error) {
+	fs := newFlagSet("send")
+	regId := fs.String("regid", "", "regId, 多个用逗号分隔")
+	alias := fs.String("alias", "", "alias, 多个用逗号分隔")
+	account := fs.String("account", "", "account, 多个用逗号分隔")
+	topic := fs.String("topic", "", "topic")
+	topics := fs.String("topics", "", "多个 topic, 用逗号分隔")
+	topicOP := fs.String("op", "", "多个 topic 的运算 UNION, INTERSECTION, EXCEPT")
+	all := fs.Bool("all", false, "发送给所有设备")
+	title := fs.String("title", "", "标题")
+	description := fs.String("desc", "", "描述")
+	payload := fs.String("payload", "", "payload")
+	passThrough := fs.Bool("pass-through", false, "透传消息")
+	notifyType := fs.Int("notify-type", 1, "提示方式 -1, 1, 2, 4")
+	jobKey := fs.String("job-key", "", "jobKey")
+	schedule := fs.String("schedule", "", "定时发送时间, RFC3339 格式")
+	extra := extraFlag{}
+	fs.Var(extra, "extra", "extra 参数 key=value, 可重复")
+	fs.Parse(args)
+
+	message := xmpush.NewMessage(*title, *description).
+		SetPayload(*payload).
+		SetNotifyType(int32(*notifyType))
+	if *passThrough {
+		message.EnablePassThrough()
+	}
+	if *jobKey != "" {
+		message.SetJobKey(*jobKey)
+	}
+	for k, v := range extra {
+		message.AddExtra(k, v)
+	}
+
+	var targets []xmpush.Target
+	if *regId != "" {
+		targets = append(targets, xmpush.RegIdTarget(splitList(*regId)...))
+	}
+	if *alias != "" {
+		targets = append(targets, xmpush.AliasTarget(splitList(*alias)...))
+	}
+	if *account != "" {
+		targets = append(targets, xmpush.AccountTarget(splitList(*account)...))
+	}
+	if *topic != "" {
+		targets = append(targets, xmpush.TopicTarget(*topic))
+	}
+	if *topics != "" {
+		targets = append(targets, xmpush.TopicsTarget(xmpush.TopicOP(*topicOP), splitList(*topics)...))
+	}
+	if *all {
+		targets = append(targets, xmpush.AllTarget())
+	}
+
+	if len(targets) != 1 {
+		return nil, errors.New("need exactly one of -regid, -alias, -account, -topic, -topics, -all")
+	}
+
+	if *schedule != "" {
+		at, err := time.Parse(time.RFC3339, *schedule)
+		if err != nil {
+			return nil, err
+		}
+
+		job, err := client.Schedule(message, targets[0], at)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"id": job.ID, "at": job.At.Format(time.RFC3339)}, nil
+	}
+
+	return client.Send(message, targets[0])
+}
+
+func runStats(client *xmpush.Client, args []string) (interface{}, error) {
+	format := "20060102"
+	fs := newFlagSet("stats")
+	start := fs.String("start", time.Now().AddDate(0, 0, -7).Format(format), "开始日期 yyyyMMdd")
+	end := fs.String("end", time.Now().Format(format), "结束日期 yyyyMMdd")
+	packageName := fs.String("package", "", "只查询指定 package")
+	fs.Parse(args)
+
+	if *packageName != "" {
+		c, err := client.ForPackage(*packageName)
+		if err != nil {
+			return nil, err
+		}
+		client = c
+	}
+
+	return client.Stats(*start, *end)
+}
+
+func runTrace(client *xmpush.Client, args []string) (interface{}, error) {
+	fs := newFlagSet("trace")
+	id := fs.String("id", "", "消息 id")
+	jobKey := fs.String("job-key", "", "jobKey")
+	since := fs.Duration("since", 0, "查询最近一段时间的消息, 如 1h")
+	begin := fs.Int64("begin", 0, "开始时间, 毫秒")
+	end := fs.Int64("end", 0, "结束时间, 毫秒")
+	fs.Parse(args)
+
+	switch {
+	case *id != "":
+		return client.GetMessageStatusByMessageId(*id)
+	case *jobKey != "":
+		return client.GetMessageStatusByJobKey(*jobKey)
+	case *since > 0:
+		now := time.Now()
+		return client.GetMessageStatusByRange(millis(now.Add(-*since)), millis(now))
+	case *begin > 0:
+		if *end == 0 {
+			*end = millis(time.Now())
+		}
+		return client.GetMessageStatusByRange(*begin, *end)
+	default:
+		return nil, errors.New("need one of -id, -job-key, -since, -begin")
+	}
+}
+
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func runSubscribe(client *xmpush.Client, args []string) (interface{}, error) {
+	return subscribe(client, "subscribe", args)
+}
+
+func runUnsubscribe(client *xmpush.Client, args []string) (interface{}, error) {
+	return subscribe(client, "unsubscribe", args)
+}
+
+func subscribe(client *xmpush.Client, name string, args []string) (interface{}, error) {
+	fs := newFlagSet(name)
+	regId := fs.String("regid", "", "regId, 多个用逗号分隔")
+	alias := fs.String("alias", "", "alias, 多个用逗号分隔")
+	topic := fs.String("topic", "", "topic")
+	category := fs.String("category", "", "category")
+	fs.Parse(args)
+
+	if *topic == "" {
+		return nil, errors.New("-topic is required")
+	}
+
+	switch {
+	case *regId != "" && *alias == "":
+		targets := splitList(*regId)
+		if name == "subscribe" {
+			return client.SubscribeForRegId(&targets, *topic, *category)
+		}
+		return client.UnsubscribeForRegId(&targets, *topic, *category)
+	case *alias != "" && *regId == "":
+		targets := splitList(*alias)
+		if name == "subscribe" {
+			return client.SubscribeForAlias(&targets, *topic, *category)
+		}
+		return client.UnsubscribeForAlias(&targets, *topic, *category)
+	default:
+		return nil, errors.New("need one of -regid, -alias")
+	}
+}
+
+func runInvalidRegIds(client *xmpush.Client, args []string) (interface{}, error) {
+	newFlagSet("invalid-regids").Parse(args)
+	return client.FetchInvalidRegIds()
+}
+
+func runAlias(client *xmpush.Client, args []string) (interface{}, error) {
+	fs := newFlagSet("alias")
+	regId := fs.String("regid", "", "regId")
+	fs.Parse(args)
+
+	if *regId == "" {
+		return nil, errors.New("-regid is required")
+	}
+	return client.GetRegIdAlias(*regId)
+}
+
+func runTopics(client *xmpush.Client, args []string) (interface{}, error) {
+	fs := newFlagSet("topics")
+	regId := fs.String("regid", "", "regId")
+	fs.Parse(args)
+
+	if *regId == "" {
+		return nil, errors.New("-regid is required")
+	}
+	return client.GetRegIdTopic(*regId)
+}
+
+func runSchedule(client *xmpush.Client, args []string) (interface{}, error) {
+	if len(args) == 0 || (args[0] != "exist" && args[0] != "delete") {
+		return nil, errors.New("usage: xmpush schedule exist|delete -id id | -job-key jobKey")
+	}
+
+	action := args[0]
+	fs := newFlagSet("schedule " + action)
+	id := fs.String("id", "", "消息 id")
+	jobKey := fs.String("job-key", "", "jobKey")
+	fs.Parse(args[1:])
+
+	switch {
+	case *id != "" && action == "exist":
+		return client.ScheduleJobExist(*id)
+	case *id != "":
+		return client.ScheduleJobDelete(*id)
+	case *jobKey != "" && action == "exist":
+		return client.ScheduleJobExistByJobKey(*jobKey)
+	case *jobKey != "":
+		return client.ScheduleJobDeleteByJobKey(*jobKey)
+	default:
+		return nil, errors.New("need one of -id, -job-key")
+	}
+}
@@ -0,0 +1,190 @@
+/*
+xmpush 命令行工具
+
+	xmpush [-config file] [-sandbox] [-output json|table] [-debug] <command> [flags]
+
+凭证从 -config 指定的 json 文件读取, 环境变量 XMPUSH_APP_SECRET, XMPUSH_PACKAGE_NAME
+(多个 package 用逗号分隔), XMPUSH_SANDBOX 会覆盖配置文件中的值
+
+	{
+	  "appSecret": "appSecret",
+	  "packageName": ["com.server.example"],
+	  "useSandbox": false
+	}
+
+命令
+
+	send            发送消息 (regid, alias, account, topic, topics, all)
+	stats           消息统计数据
+	trace           追踪消息状态 (id, jobkey, 时间范围)
+	subscribe       订阅 topic
+	unsubscribe     取消订阅 topic
+	invalid-regids  获取失效的 regId
+	alias           regId 设置的所有 alias
+	topics          regId 订阅的所有 topic
+	schedule        定时任务管理 (exist, delete)
+*/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/xinpianchang/xmpush"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(client *xmpush.Client, args []string) (interface{}, error)
+}
+
+var commands = []command{
+	{"send", "发送消息", runSend},
+	{"stats", "消息统计数据", runStats},
+	{"trace", "追踪消息状态", runTrace},
+	{"subscribe", "订阅 topic", runSubscribe},
+	{"unsubscribe", "取消订阅 topic", runUnsubscribe},
+	{"invalid-regids", "获取失效的 regId", runInvalidRegIds},
+	{"alias", "regId 设置的所有 alias", runAlias},
+	{"topics", "regId 订阅的所有 topic", runTopics},
+	{"schedule", "定时任务管理 (exist, delete)", runSchedule},
+}
+
+func main() {
+	configFile := flag.String("config", "", "配置文件")
+	sandbox := flag.Bool("sandbox", false, "使用沙箱环境")
+	output := flag.String("output", "table", "输出格式 json, table")
+	debug := flag.Bool("debug", false, "输出请求日志")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd := findCommand(flag.Arg(0))
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command %s\n\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	config, err := loadConfig(*configFile)
+	if err != nil {
+		fatal(err)
+	}
+
+	client, err := xmpush.NewClient(config.AppSecret, config.PackageNames...)
+	if err != nil {
+		fatal(errors.New("appSecret and packageName are required"))
+	}
+	client.UseSandbox(config.UseSandbox || *sandbox)
+	if *debug {
+		client.SetLogger(&stderrLogger{log: log.New(os.Stderr, "", log.LstdFlags)})
+	}
+
+	result, err := cmd.run(client, flag.Args()[1:])
+	if err != nil {
+		fatal(err)
+	}
+
+	if err := printResult(os.Stdout, *output, result); err != nil {
+		fatal(err)
+	}
+
+	// 小米推送返回 code 不为 0 时以非 0 状态退出, 便于脚本判断
+	if err := resultError(result); err != nil {
+		fatal(err)
+	}
+}
+
+// 返回结果中 code 不为 0 时返回对应的 error
+func resultError(v interface{}) error {
+	r, ok := v.(interface{ APIResult() *xmpush.Result })
+	if !ok {
+		return nil
+	}
+	if result := r.APIResult(); result.Code != 0 {
+		return fmt.Errorf("xiaomi push API code %d, %s", result.Code, result.Description)
+	}
+	return nil
+}
+
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: xmpush [flags] <command> [command flags]\n\nflags:\n")
+	flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\n使用 xmpush <command> -h 查看命令参数\n")
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	os.Exit(1)
+}
+
+func loadConfig(file string) (*xmpush.AppConfig, error) {
+	var config xmpush.AppConfig
+	if file != "" {
+		bytes, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(bytes, &config); err != nil {
+			return nil, fmt.Errorf("parse config %s: %v", file, err)
+		}
+	}
+
+	if v := os.Getenv("XMPUSH_APP_SECRET"); v != "" {
+		config.AppSecret = v
+	}
+	if v := os.Getenv("XMPUSH_PACKAGE_NAME"); v != "" {
+		config.PackageNames = splitList(v)
+	}
+	if v := os.Getenv("XMPUSH_SANDBOX"); v != "" {
+		config.UseSandbox = v == "1" || strings.EqualFold(v, "true")
+	}
+
+	return &config, nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+type stderrLogger struct {
+	log *log.Logger
+}
+
+func (l *stderrLogger) Debug(args ...interface{}) {
+	l.log.Println("[debug]", fmt.Sprint(args...))
+}
+
+func (l *stderrLogger) Debugf(format string, args ...interface{}) {
+	l.log.Println("[debug]", fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+func printResult(w io.Writer, format string, v interface{}) error {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		printTable(tw, reflect.ValueOf(v))
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unknown output format %s", format)
+	}
+}
+
+// 标量字段按 key value 输出, 结构体数组按表格输出
+func printTable(w io.Writer, v reflect.Value) {
+	var tables []reflect.Value
+	var names []string
+
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return
+			}
+			v = v.Elem()
+		}
+
+		switch v.Kind() {
+		case reflect.Struct:
+			t := v.Type()
+			for i := 0; i < t.NumField(); i++ {
+				field := t.Field(i)
+				if field.PkgPath != "" {
+					continue
+				}
+
+				name := prefix
+				if !field.Anonymous {
+					name = joinName(prefix, jsonName(field))
+				}
+				walk(name, v.Field(i))
+			}
+		case reflect.Map:
+			// 按 key 排序, 保证每次输出顺序一致
+			keys := v.MapKeys()
+			sort.Slice(keys, func(i, j int) bool {
+				return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+			})
+			for _, key := range keys {
+				walk(joinName(prefix, fmt.Sprint(key.Interface())), v.MapIndex(key))
+			}
+		case reflect.Slice, reflect.Array:
+			elem := v.Type().Elem()
+			for elem.Kind() == reflect.Ptr {
+				elem = elem.Elem()
+			}
+			if elem.Kind() == reflect.Struct {
+				tables = append(tables, v)
+				names = append(names, prefix)
+				return
+			}
+
+			items := make([]string, 0, v.Len())
+			for i := 0; i < v.Len(); i++ {
+				items = append(items, fmt.Sprint(v.Index(i).Interface()))
+			}
+			fmt.Fprintf(w, "%s\t%s\n", prefix, strings.Join(items, ","))
+		default:
+			fmt.Fprintf(w, "%s\t%v\n", prefix, v.Interface())
+		}
+	}
+	walk("", v)
+
+	for i, table := range tables {
+		fmt.Fprintf(w, "\n%s\n", names[i])
+		printRows(w, table)
+	}
+}
+
+func printRows(w io.Writer, v reflect.Value) {
+	elem := v.Type().Elem()
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+
+	var header []string
+	for i := 0; i < elem.NumField(); i++ {
+		if elem.Field(i).PkgPath == "" {
+			header = append(header, jsonName(elem.Field(i)))
+		}
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+
+	for i := 0; i < v.Len(); i++ {
+		row := v.Index(i)
+		for row.Kind() == reflect.Ptr {
+			row = row.Elem()
+		}
+
+		var cells []string
+		for j := 0; j < elem.NumField(); j++ {
+			if elem.Field(j).PkgPath == "" {
+				cells = append(cells, fmt.Sprint(row.Field(j).Interface()))
+			}
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+}
+
+func jsonName(field reflect.StructField) string {
+	tag := strings.Split(field.Tag.Get("json"), ",")[0]
+	if tag == "" || tag == "-" {
+		return field.Name
+	}
+	return tag
+}
+
+func joinName(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/xinpianchang/xmpush"
+)
+
+func TestPrintResult(t *testing.T) {
+	type row struct {
+		Date  string `json:"date"`
+		Count int    `json:"count"`
+	}
+	result := struct {
+		Code     int               `json:"code"`
+		Packages map[string]string `json:"packages"`
+		Tags     []string          `json:"tags"`
+		Rows     []*row            `json:"rows"`
+	}{
+		Code:     0,
+		Packages: map[string]string{"com.c": "3", "com.a": "1", "com.b": "2"},
+		Tags:     []string{"a", "b"},
+		Rows:     []*row{{Date: "20200101", Count: 1}, {Date: "20200102", Count: 2}},
+	}
+
+	var first string
+	for i := 0; i < 10; i++ {
+		var buf bytes.Buffer
+		if err := printResult(&buf, "table", &result); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			first = buf.String()
+		} else if buf.String() != first {
+			t.Fatalf("unstable output:\n%s\n%s", first, buf.String())
+		}
+	}
+
+	expected := []string{
+		"code            0",
+		"packages.com.a  1",
+		"packages.com.b  2",
+		"packages.com.c  3",
+		"tags            a,b",
+		"",
+		"rows",
+		"date      count",
+		"20200101  1",
+		"20200102  2",
+	}
+	if lines := strings.Split(strings.TrimRight(first, "\n"), "\n"); strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("%q", lines)
+	}
+
+	var buf bytes.Buffer
+	if err := printResult(&buf, "json", map[string]int{"b": 2, "a": 1}); err != nil || buf.String() != "{\n  \"a\": 1,\n  \"b\": 2\n}\n" {
+		t.Fatalf("%q %v", buf.String(), err)
+	}
+
+	if err := printResult(&buf, "xml", result); err == nil {
+		t.Fatal("expect unknown format error")
+	}
+}
+
+func TestResultError(t *testing.T) {
+	if err := resultError(&xmpush.SendResult{}); err != nil {
+		t.Fatal(err)
+	}
+
+	result := &xmpush.SendResult{Result: xmpush.Result{Code: 22006, Description: "invalid appSecret"}}
+	if err := resultError(result); err == nil || !strings.Contains(err.Error(), "22006") {
+		t.Fatal(err)
+	}
+
+	if err := resultError(map[string]int{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+}
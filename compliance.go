@@ -0,0 +1,260 @@
+package xmpush
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 消息内容检查, 在消息编码前调用, 返回错误时拒绝发送
+type ContentChecker interface {
+	Check(message *Message) error
+}
+
+type ContentCheckerFunc func(message *Message) error
+
+func (f ContentCheckerFunc) Check(message *Message) error {
+	return f(message)
+}
+
+// 依次执行的多个检查, 返回第一个错误
+type ContentCheckers []ContentChecker
+
+func (cs ContentCheckers) Check(message *Message) error {
+	for _, checker := range cs {
+		if err := checker.Check(message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 设置发送前的内容检查, 多个检查按顺序执行, 不传参数时取消检查
+func (c *Client) SetContentChecker(checkers ...ContentChecker) {
+	switch len(checkers) {
+	case 0:
+		c.contentChecker = nil
+	case 1:
+		c.contentChecker = checkers[0]
+	default:
+		c.contentChecker = ContentCheckers(checkers)
+	}
+}
+
+func (c *Client) checkContent(message *Message) error {
+	if c.contentChecker == nil {
+		return nil
+	}
+	return c.contentChecker.Check(message)
+}
+
+// 检查规则
+const (
+	RuleKeyword    = "keyword"
+	RuleURL        = "url"
+	RuleQuietHours = "quiet_hours"
+)
+
+// 内容检查拒绝发送的错误
+type ComplianceError struct {
+	Rule   string // 触发的规则
+	Field  string // 触发规则的字段
+	Reason string
+}
+
+func (e *ComplianceError) Error() string {
+	return fmt.Sprintf("rejected by %s rule, %s: %s", e.Rule, e.Field, e.Reason)
+}
+
+// 敏感词过滤, 检查标题、描述、状态栏文字 (extra.ticker) 和透传消息的 payload, 关键词不区分大小写
+func NewKeywordFilter(keywords []string, patterns []string) (*KeywordFilter, error) {
+	f := &KeywordFilter{}
+	f.AddKeywords(keywords...)
+	if err := f.AddPatterns(patterns...); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// 从词典读取敏感词, 每行一个, 以 re: 开头的为正则表达式, 以 # 开头的为注释
+func LoadKeywordFilter(r io.Reader) (*KeywordFilter, error) {
+	f := &KeywordFilter{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "re:"):
+			if err := f.AddPatterns(strings.TrimPrefix(line, "re:")); err != nil {
+				return nil, err
+			}
+		default:
+			f.AddKeywords(line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+type KeywordFilter struct {
+	keywords []string
+	patterns []*regexp.Regexp
+}
+
+func (f *KeywordFilter) AddKeywords(keywords ...string) *KeywordFilter {
+	for _, keyword := range keywords {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			f.keywords = append(f.keywords, strings.ToLower(keyword))
+		}
+	}
+	return f
+}
+
+func (f *KeywordFilter) AddPatterns(patterns ...string) error {
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid keyword pattern %q: %v", pattern, err)
+		}
+		f.patterns = append(f.patterns, re)
+	}
+	return nil
+}
+
+type textField struct {
+	name  string
+	value string
+}
+
+func (f *KeywordFilter) Check(message *Message) error {
+	fields := []textField{
+		{"title", message.Title},
+		{"description", message.Description},
+		{"extra.ticker", message.Extra["ticker"]},
+	}
+	if message.PassThrough == 1 {
+		fields = append(fields, textField{"payload", message.Payload})
+	}
+
+	for _, field := range fields {
+		lower := strings.ToLower(field.value)
+		for _, keyword := range f.keywords {
+			if strings.Contains(lower, keyword) {
+				return &ComplianceError{Rule: RuleKeyword, Field: field.name, Reason: fmt.Sprintf("contains %q", keyword)}
+			}
+		}
+		for _, re := range f.patterns {
+			if match := re.FindString(field.value); match != "" {
+				return &ComplianceError{Rule: RuleKeyword, Field: field.name, Reason: fmt.Sprintf("contains %q", match)}
+			}
+		}
+	}
+
+	return nil
+}
+
+// SetOpenWebURI 的域名白名单, 包括子域名
+func NewURLAllowList(hosts ...string) *URLAllowList {
+	l := &URLAllowList{}
+	for _, host := range hosts {
+		l.hosts = append(l.hosts, strings.ToLower(strings.TrimPrefix(host, ".")))
+	}
+	return l
+}
+
+type URLAllowList struct {
+	hosts []string
+}
+
+func (l *URLAllowList) Check(message *Message) error {
+	uri, ok := message.Extra["web_uri"]
+	if !ok {
+		return nil
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil || u.Hostname() == "" {
+		return &ComplianceError{Rule: RuleURL, Field: "extra.web_uri", Reason: "invalid url"}
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range l.hosts {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return nil
+		}
+	}
+
+	return &ComplianceError{Rule: RuleURL, Field: "extra.web_uri", Reason: fmt.Sprintf("host %s not allowed", host)}
+}
+
+// 免打扰时段, 时间格式为 HH:MM, start 大于 end 时跨越零点, 如 22:00 ~ 08:00
+//
+// 定时消息按 TimeToSend 检查, 其他消息按当前时间检查, 透传消息不检查
+func NewQuietHours(start string, end string, loc *time.Location) (*QuietHours, error) {
+	s, err := parseClock(start)
+	if err != nil {
+		return nil, err
+	}
+	e, err := parseClock(end)
+	if err != nil {
+		return nil, err
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+
+	return &QuietHours{start: s, end: e, loc: loc, now: time.Now}, nil
+}
+
+type QuietHours struct {
+	start time.Duration
+	end   time.Duration
+	loc   *time.Location
+	now   func() time.Time
+}
+
+func parseClock(clock string) (time.Duration, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid clock %q, should be HH:MM", clock)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// t 是否在免打扰时段
+func (q *QuietHours) Contains(t time.Time) bool {
+	t = t.In(q.loc)
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+
+	if q.start <= q.end {
+		return clock >= q.start && clock < q.end
+	}
+	return clock >= q.start || clock < q.end
+}
+
+func (q *QuietHours) Check(message *Message) error {
+	if message.PassThrough == 1 {
+		return nil
+	}
+
+	field, at := "time_to_send", q.now()
+	if message.TimeToSend > 0 {
+		at = time.Unix(0, message.TimeToSend*int64(time.Millisecond))
+	} else {
+		field = "send_time"
+	}
+
+	if q.Contains(at) {
+		reason := fmt.Sprintf("%s is in quiet hours", at.In(q.loc).Format("15:04"))
+		return &ComplianceError{Rule: RuleQuietHours, Field: field, Reason: reason}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package xmpush
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeywordFilter(t *testing.T) {
+	f, err := LoadKeywordFilter(strings.NewReader("# 广告法\n最好\nFREE\nre:[0-9]{11}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Check(NewMessage("新品上架", "快来看看")); err != nil {
+		t.Fatal(err)
+	}
+
+	err = f.Check(NewMessage("全网最好的手机", "description"))
+	if e, ok := err.(*ComplianceError); !ok || e.Rule != RuleKeyword || e.Field != "title" {
+		t.Fatal(err)
+	}
+
+	err = f.Check(NewMessage("title", "Free gift, call 13800138000"))
+	if e, ok := err.(*ComplianceError); !ok || e.Field != "description" {
+		t.Fatal(err)
+	}
+
+	err = f.Check(NewMessage("title", "description").SetTicker("最好的优惠"))
+	if e, ok := err.(*ComplianceError); !ok || e.Field != "extra.ticker" {
+		t.Fatal(err)
+	}
+
+	passThrough := NewMessage("title", "description").EnablePassThrough()
+	passThrough.Payload = `{"text":"free"}`
+	err = f.Check(passThrough)
+	if e, ok := err.(*ComplianceError); !ok || e.Field != "payload" {
+		t.Fatal(err)
+	}
+
+	// 通知栏消息的 payload 不展示给用户
+	notification := NewMessage("title", "description")
+	notification.Payload = `{"text":"free"}`
+	if err := f.Check(notification); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewKeywordFilter(nil, []string{"("}); err == nil {
+		t.Fatal("expect invalid pattern error")
+	}
+}
+
+func TestURLAllowList(t *testing.T) {
+	l := NewURLAllowList("example.com")
+
+	for uri, allowed := range map[string]bool{
+		"https://example.com/a":        true,
+		"https://m.example.com/a":      true,
+		"https://badexample.com/a":     false,
+		"https://example.com.cn/a":     false,
+		"http://evil.com/?example.com": false,
+	} {
+		err := l.Check(NewMessage("title", "description").SetOpenWebURI(uri))
+		if (err == nil) != allowed {
+			t.Fatal(uri, err)
+		}
+	}
+
+	if err := l.Check(NewMessage("title", "description")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	q, err := NewQuietHours("22:00", "08:00", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q.now = func() time.Time { return time.Date(2020, 1, 1, 23, 30, 0, 0, loc) }
+	err = q.Check(NewMessage("title", "description"))
+	if e, ok := err.(*ComplianceError); !ok || e.Rule != RuleQuietHours {
+		t.Fatal(err)
+	}
+
+	if err := q.Check(NewMessage("title", "description").EnablePassThrough()); err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Date(2020, 1, 2, 9, 0, 0, 0, loc)
+	scheduled := NewMessage("title", "description")
+	scheduled.TimeToSend = at.UnixNano() / int64(time.Millisecond)
+	if err := q.Check(scheduled); err != nil {
+		t.Fatal(err)
+	}
+
+	if q.Contains(time.Date(2020, 1, 2, 8, 0, 0, 0, loc)) || !q.Contains(time.Date(2020, 1, 2, 7, 59, 0, 0, loc)) {
+		t.Fatal("quiet hours boundary")
+	}
+
+	if _, err := NewQuietHours("25:00", "08:00", loc); err == nil {
+		t.Fatal("expect invalid clock error")
+	}
+}
+
+func TestClient_SetContentChecker(t *testing.T) {
+	c, err := NewClient("secret", "com.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetContentChecker(NewURLAllowList("example.com"), ContentCheckerFunc(func(m *Message) error {
+		if m.Title == "" {
+			return &ComplianceError{Rule: "custom", Field: "title", Reason: "empty"}
+		}
+		return nil
+	}))
+
+	message := NewMessage("title", "description").SetOpenWebURI("https://evil.com")
+	if _, err := c.SendToRegId(message, &[]string{"r1"}); err == nil {
+		t.Fatal("expect rejected")
+	} else if _, ok := err.(*ComplianceError); !ok {
+		t.Fatal(err)
+	}
+
+	targeted := []TargetedMessage{*NewTargetedMessage(message, "r1", TargetRegId)}
+	if _, err := c.SendTargetedMessage(&targeted); err == nil {
+		t.Fatal("expect rejected")
+	}
+}
@@ -0,0 +1,324 @@
+/*
+FCM HTTP v1 推送, 实现 xmpush.Pusher 接口
+
+	client := fcm.NewClient("project-id", fcm.StaticToken("oauth2 access token"))
+	result, err := client.Send(&xmpush.Notification{Title: "title", Body: "body"}, tokens)
+
+access token 需要 https://www.googleapis.com/auth/firebase.messaging 权限,
+可以使用 golang.org/x/oauth2/google 获取后通过 TokenFunc 传入
+*/
+package fcm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xinpianchang/xmpush"
+)
+
+const (
+	defaultEndpoint    = "https://fcm.googleapis.com"
+	defaultIIDEndpoint = "https://iid.googleapis.com"
+)
+
+// 获取 OAuth2 access token
+type TokenSource interface {
+	Token() (string, error)
+}
+
+type TokenFunc func() (string, error)
+
+func (f TokenFunc) Token() (string, error) {
+	return f()
+}
+
+// 固定的 access token
+type StaticToken string
+
+func (t StaticToken) Token() (string, error) {
+	return string(t), nil
+}
+
+func NewClient(projectID string, tokens TokenSource) *Client {
+	return &Client{
+		projectID:   projectID,
+		tokens:      tokens,
+		endpoint:    defaultEndpoint,
+		iidEndpoint: defaultIIDEndpoint,
+		client: &http.Client{
+			Timeout: 20 * time.Second,
+		},
+	}
+}
+
+type Client struct {
+	projectID   string
+	tokens      TokenSource
+	endpoint    string
+	iidEndpoint string
+	client      *http.Client
+
+	mu      sync.Mutex
+	invalid []string
+}
+
+var _ xmpush.Pusher = (*Client)(nil)
+
+// 设置 FCM 接口地址, 可用于测试
+func (c *Client) SetEndpoint(endpoint string) {
+	c.endpoint = strings.TrimSuffix(endpoint, "/")
+}
+
+// 设置 topic 订阅接口地址, 可用于测试
+func (c *Client) SetIIDEndpoint(endpoint string) {
+	c.iidEndpoint = strings.TrimSuffix(endpoint, "/")
+}
+
+func (c *Client) SetHTTPClient(client *http.Client) {
+	if client != nil {
+		c.client = client
+	}
+}
+
+type message struct {
+	Token        string            `json:"token,omitempty"`
+	Topic        string            `json:"topic,omitempty"`
+	Notification *notification     `json:"notification,omitempty"`
+	Data         map[string]string `json:"data,omitempty"`
+	Android      *androidConfig    `json:"android,omitempty"`
+}
+
+type notification struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+type androidConfig struct {
+	CollapseKey  string               `json:"collapse_key,omitempty"`
+	TTL          string               `json:"ttl,omitempty"`
+	Notification *androidNotification `json:"notification,omitempty"`
+}
+
+type androidNotification struct {
+	Tag               string `json:"tag,omitempty"`
+	NotificationCount int64  `json:"notification_count,omitempty"`
+}
+
+// 点击打开的网页放在 data 中, 由客户端处理
+const clickURLKey = "click_url"
+
+func toMessage(n *xmpush.Notification) *message {
+	m := &message{}
+
+	if !n.DataOnly {
+		m.Notification = &notification{Title: n.Title, Body: n.Body}
+	}
+
+	if len(n.Data) > 0 || n.ClickURL != "" {
+		m.Data = make(map[string]string, len(n.Data)+1)
+		for k, v := range n.Data {
+			m.Data[k] = v
+		}
+		if n.ClickURL != "" {
+			m.Data[clickURLKey] = n.ClickURL
+		}
+	}
+
+	android := &androidConfig{CollapseKey: n.CollapseKey}
+	if n.TTL > 0 {
+		android.TTL = fmt.Sprintf("%ds", int64(n.TTL/time.Second))
+	}
+	if !n.DataOnly && (n.CollapseKey != "" || n.Badge > 0) {
+		android.Notification = &androidNotification{Tag: n.CollapseKey, NotificationCount: n.Badge}
+	}
+	if android.CollapseKey != "" || android.TTL != "" || android.Notification != nil {
+		m.Android = android
+	}
+
+	return m
+}
+
+// FCM 接口错误
+type Error struct {
+	StatusCode int
+	Status     string // 如 NOT_FOUND, INVALID_ARGUMENT
+	ErrorCode  string // 如 UNREGISTERED
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("fcm API status %d %s %s: %s", e.StatusCode, e.Status, e.ErrorCode, e.Message)
+}
+
+// token 是否已经失效
+func (e *Error) InvalidToken() bool {
+	return e.ErrorCode == "UNREGISTERED" ||
+		(e.Status == "INVALID_ARGUMENT" && strings.Contains(e.Message, "registration token"))
+}
+
+// 逐个 token 发送, 失效的 token 放在 InvalidTokens 中, 同时记录到 Feedback
+func (c *Client) Send(n *xmpush.Notification, tokens []string) (*xmpush.PushResult, error) {
+	if len(tokens) == 0 {
+		return nil, errors.New("tokens can't empty")
+	}
+
+	var result xmpush.PushResult
+	// 中途失败返回时, 之前发现的失效 token 也要记录
+	defer func() {
+		if len(result.InvalidTokens) > 0 {
+			c.mu.Lock()
+			c.invalid = append(c.invalid, result.InvalidTokens...)
+			c.mu.Unlock()
+		}
+	}()
+
+	for _, token := range tokens {
+		m := toMessage(n)
+		m.Token = token
+
+		id, err := c.send(m)
+		if e, ok := err.(*Error); ok && e.InvalidToken() {
+			result.InvalidTokens = append(result.InvalidTokens, token)
+			continue
+		}
+		if err != nil {
+			return &result, err
+		}
+		result.MessageIDs = append(result.MessageIDs, id)
+		result.Delivered = append(result.Delivered, token)
+	}
+
+	return &result, nil
+}
+
+func (c *Client) SendToTopic(n *xmpush.Notification, topic string) (*xmpush.PushResult, error) {
+	m := toMessage(n)
+	m.Topic = topic
+
+	id, err := c.send(m)
+	if err != nil {
+		return nil, err
+	}
+	return &xmpush.PushResult{MessageIDs: []string{id}}, nil
+}
+
+func (c *Client) send(m *message) (string, error) {
+	var res struct {
+		Name string `json:"name"`
+	}
+
+	api := fmt.Sprintf("%s/v1/projects/%s/messages:send", c.endpoint, c.projectID)
+	if err := c.post(api, map[string]interface{}{"message": m}, nil, &res); err != nil {
+		return "", err
+	}
+	return res.Name, nil
+}
+
+func (c *Client) Subscribe(tokens []string, topic string) error {
+	return c.topicAction("batchAdd", tokens, topic)
+}
+
+func (c *Client) Unsubscribe(tokens []string, topic string) error {
+	return c.topicAction("batchRemove", tokens, topic)
+}
+
+func (c *Client) topicAction(action string, tokens []string, topic string) error {
+	if len(tokens) == 0 || topic == "" {
+		return errors.New("tokens and topic can't empty")
+	}
+
+	body := map[string]interface{}{
+		"to":                  "/topics/" + topic,
+		"registration_tokens": tokens,
+	}
+	header := http.Header{"access_token_auth": []string{"true"}}
+
+	return c.post(fmt.Sprintf("%s/iid/v1:%s", c.iidEndpoint, action), body, header, nil)
+}
+
+// FCM 没有失效 token 查询接口, 返回之前发送时发现的失效 token, 返回后清空
+func (c *Client) Feedback() ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	invalid := c.invalid
+	c.invalid = nil
+	return invalid, nil
+}
+
+func (c *Client) post(api string, body interface{}, header http.Header, v interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	token, err := c.tokens.Token()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", api, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	for k, values := range header {
+		req.Header[k] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return parseError(res.StatusCode, resBody)
+	}
+
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(resBody, v)
+}
+
+func parseError(statusCode int, body []byte) error {
+	var res struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+			Details []struct {
+				Type      string `json:"@type"`
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+
+	e := &Error{StatusCode: statusCode, Message: string(body)}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return e
+	}
+
+	e.Status = res.Error.Status
+	e.Message = res.Error.Message
+	for _, detail := range res.Error.Details {
+		if detail.ErrorCode != "" {
+			e.ErrorCode = detail.ErrorCode
+		}
+	}
+	return e
+}
@@ -0,0 +1,132 @@
+package fcm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xinpianchang/xmpush"
+	"github.com/xinpianchang/xmpush/fcm"
+	"github.com/xinpianchang/xmpush/fcm/fcmtest"
+)
+
+func newClient(server *fcmtest.Server) *fcm.Client {
+	client := fcm.NewClient("demo", fcm.StaticToken("secret"))
+	client.SetEndpoint(server.URL)
+	client.SetIIDEndpoint(server.URL)
+	return client
+}
+
+func TestClient_Send(t *testing.T) {
+	server := fcmtest.NewServer("secret")
+	defer server.Close()
+	server.Unregister("stale")
+
+	client := newClient(server)
+	n := &xmpush.Notification{
+		Title:       "title",
+		Body:        "body",
+		Data:        map[string]string{"id": "1"},
+		ClickURL:    "https://example.com",
+		CollapseKey: "chat:1",
+		TTL:         time.Hour,
+	}
+
+	result, err := client.Send(n, []string{"token-1", "stale", "token-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.MessageIDs) != 2 || len(result.InvalidTokens) != 1 || result.InvalidTokens[0] != "stale" ||
+		len(result.Delivered) != 2 || result.Delivered[1] != "token-2" {
+		t.Fatal(result)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 2 || messages[0].Project != "demo" || messages[0].Token != "token-1" {
+		t.Fatal(messages)
+	}
+	data := messages[0].Raw["data"].(map[string]interface{})
+	android := messages[0].Raw["android"].(map[string]interface{})
+	if data["id"] != "1" || data["click_url"] != "https://example.com" ||
+		android["ttl"] != "3600s" || android["collapse_key"] != "chat:1" {
+		t.Fatal(messages[0].Raw)
+	}
+
+	feedback, err := client.Feedback()
+	if err != nil || len(feedback) != 1 || feedback[0] != "stale" {
+		t.Fatal(feedback, err)
+	}
+	if feedback, _ := client.Feedback(); len(feedback) != 0 {
+		t.Fatal(feedback)
+	}
+}
+
+func TestClient_SendError(t *testing.T) {
+	server := fcmtest.NewServer("secret")
+	defer server.Close()
+	server.FailNext(1)
+
+	client := newClient(server)
+	result, err := client.Send(&xmpush.Notification{Title: "title", Body: "body"}, []string{"token"})
+	if e, ok := err.(*fcm.Error); !ok || e.StatusCode != 503 || e.InvalidToken() || len(result.Delivered) != 0 {
+		t.Fatal(err)
+	}
+
+	// 中途失败时, 之前发现的失效 token 也记录到 Feedback
+	server.Unregister("stale")
+	result, err = client.Send(&xmpush.Notification{Title: "title", Body: "body"}, []string{"stale", ""})
+	if err == nil || len(result.InvalidTokens) != 1 {
+		t.Fatal(result, err)
+	}
+	if feedback, _ := client.Feedback(); len(feedback) != 1 || feedback[0] != "stale" {
+		t.Fatal(feedback)
+	}
+
+	client = fcm.NewClient("demo", fcm.StaticToken("wrong"))
+	client.SetEndpoint(server.URL)
+	if _, err := client.SendToTopic(&xmpush.Notification{Title: "title"}, "news"); err == nil {
+		t.Fatal("expect unauthenticated error")
+	}
+}
+
+func TestClient_Subscribe(t *testing.T) {
+	server := fcmtest.NewServer("secret")
+	defer server.Close()
+
+	client := newClient(server)
+	if err := client.Subscribe([]string{"a", "b"}, "news"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Unsubscribe([]string{"a"}, "news"); err != nil {
+		t.Fatal(err)
+	}
+	if tokens := server.Subscribers("news"); len(tokens) != 1 || tokens[0] != "b" {
+		t.Fatal(tokens)
+	}
+
+	result, err := client.SendToTopic(&xmpush.Notification{Title: "title", Body: "body"}, "news")
+	if err != nil || len(result.MessageIDs) != 1 {
+		t.Fatal(result, err)
+	}
+}
+
+func TestRoutingPusher(t *testing.T) {
+	server := fcmtest.NewServer("secret")
+	defer server.Close()
+	server.Unregister("fcm-stale")
+
+	router := xmpush.NewRoutingPusher(xmpush.VendorFCM, map[string]xmpush.Pusher{
+		xmpush.VendorFCM: newClient(server),
+	})
+	router.SetFallbackPolicy(xmpush.FallbackOnInvalidToken)
+
+	result, err := router.Send(&xmpush.Notification{Title: "title", Body: "body"}, []xmpush.Device{
+		{Token: "fcm-1"},
+		{Token: "fcm-stale"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Results[xmpush.VendorFCM].MessageIDs) != 1 || len(result.Failed) != 1 {
+		t.Fatal(result)
+	}
+}
@@ -0,0 +1,199 @@
+/*
+本地 FCM 替身, 用于测试 fcm.Client 和基于它的推送逻辑
+
+	server := fcmtest.NewServer("access-token")
+	defer server.Close()
+	server.Unregister("stale-token")
+
+	client := fcm.NewClient("project-id", fcm.StaticToken("access-token"))
+	client.SetEndpoint(server.URL)
+	client.SetIIDEndpoint(server.URL)
+*/
+package fcmtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// 收到的消息
+type Message struct {
+	Project string                 `json:"-"`
+	Token   string                 `json:"token,omitempty"`
+	Topic   string                 `json:"topic,omitempty"`
+	Raw     map[string]interface{} `json:"-"`
+}
+
+type Server struct {
+	*httptest.Server
+	accessToken string
+
+	mu            sync.Mutex
+	messages      []Message
+	unregistered  map[string]bool
+	subscriptions map[string]map[string]bool
+	failures      int
+}
+
+// accessToken 为空时不校验 Authorization
+func NewServer(accessToken string) *Server {
+	s := &Server{
+		accessToken:   accessToken,
+		unregistered:  make(map[string]bool),
+		subscriptions: make(map[string]map[string]bool),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// 标记 token 已失效, 发送时返回 UNREGISTERED
+func (s *Server) Unregister(tokens ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, token := range tokens {
+		s.unregistered[token] = true
+	}
+}
+
+// 接下来的 n 次请求返回 503
+func (s *Server) FailNext(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = n
+}
+
+// 已收到的消息
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// 订阅了 topic 的 token
+func (s *Server) Subscribers(topic string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tokens []string
+	for token := range s.subscriptions[topic] {
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "INVALID_ARGUMENT", "", "method not allowed")
+		return
+	}
+	if s.accessToken != "" && r.Header.Get("Authorization") != "Bearer "+s.accessToken {
+		writeError(w, http.StatusUnauthorized, "UNAUTHENTICATED", "", "invalid access token")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failures > 0 {
+		s.failures--
+		writeError(w, http.StatusServiceUnavailable, "UNAVAILABLE", "UNAVAILABLE", "service unavailable")
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/v1/projects/") && strings.HasSuffix(r.URL.Path, "/messages:send"):
+		s.send(w, r)
+	case r.URL.Path == "/iid/v1:batchAdd":
+		s.topicAction(w, r, true)
+	case r.URL.Path == "/iid/v1:batchRemove":
+		s.topicAction(w, r, false)
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "", "unknown path "+r.URL.Path)
+	}
+}
+
+func (s *Server) send(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Message map[string]interface{} `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Message == nil {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "INVALID_ARGUMENT", "invalid message")
+		return
+	}
+
+	project := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/projects/"), "/messages:send")
+	m := Message{Project: project, Raw: req.Message}
+	m.Token, _ = req.Message["token"].(string)
+	m.Topic, _ = req.Message["topic"].(string)
+
+	if (m.Token == "") == (m.Topic == "") {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "INVALID_ARGUMENT", "need one of token, topic")
+		return
+	}
+	if m.Token != "" && s.unregistered[m.Token] {
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "UNREGISTERED", "Requested entity was not found.")
+		return
+	}
+
+	s.messages = append(s.messages, m)
+	writeJSON(w, http.StatusOK, map[string]string{
+		"name": fmt.Sprintf("projects/%s/messages/%d", project, len(s.messages)),
+	})
+}
+
+func (s *Server) topicAction(w http.ResponseWriter, r *http.Request, add bool) {
+	var req struct {
+		To     string   `json:"to"`
+		Tokens []string `json:"registration_tokens"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !strings.HasPrefix(req.To, "/topics/") {
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "", "invalid request")
+		return
+	}
+
+	topic := strings.TrimPrefix(req.To, "/topics/")
+	if s.subscriptions[topic] == nil {
+		s.subscriptions[topic] = make(map[string]bool)
+	}
+
+	results := make([]map[string]string, 0, len(req.Tokens))
+	for _, token := range req.Tokens {
+		switch {
+		case s.unregistered[token]:
+			results = append(results, map[string]string{"error": "NOT_FOUND"})
+		case add:
+			s.subscriptions[topic][token] = true
+			results = append(results, map[string]string{})
+		default:
+			delete(s.subscriptions[topic], token)
+			results = append(results, map[string]string{})
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"results": results})
+}
+
+func writeError(w http.ResponseWriter, statusCode int, status string, errorCode string, message string) {
+	e := map[string]interface{}{
+		"code":    statusCode,
+		"status":  status,
+		"message": message,
+	}
+	if errorCode != "" {
+		e["details"] = []map[string]string{{
+			"@type":     "type.googleapis.com/google.firebase.fcm.v1.FcmError",
+			"errorCode": errorCode,
+		}}
+	}
+	writeJSON(w, statusCode, map[string]interface{}{"error": e})
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
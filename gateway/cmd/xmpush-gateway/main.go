@@ -0,0 +1,96 @@
+/*
+小米推送网关服务
+
+	xmpush-gateway -config gateway.json
+
+配置文件
+
+	{
+	  "app": {
+	    "appSecret": "appSecret",
+	    "packageName": ["com.server.example"]
+	  },
+	  "callers": [
+	    {"name": "order-service", "token": "token", "ratePerSecond": 10, "burst": 20}
+	  ],
+	  "httpAddr": ":8080",
+	  "grpcAddr": ":9090"
+	}
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/xinpianchang/xmpush"
+	"github.com/xinpianchang/xmpush/gateway"
+	"google.golang.org/grpc"
+)
+
+type config struct {
+	App      xmpush.AppConfig `json:"app"`
+	Callers  []gateway.Caller `json:"callers"`
+	HTTPAddr string           `json:"httpAddr"`
+	GRPCAddr string           `json:"grpcAddr"`
+}
+
+func main() {
+	configFile := flag.String("config", "gateway.json", "配置文件")
+	flag.Parse()
+
+	bytes, err := ioutil.ReadFile(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var c config
+	if err := json.Unmarshal(bytes, &c); err != nil {
+		log.Fatal("parse config error ", err)
+	}
+
+	client, err := xmpush.NewClient(c.App.AppSecret, c.App.PackageNames...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	client.UseSandbox(c.App.UseSandbox)
+
+	gw, err := gateway.New(client, c.Callers)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if c.HTTPAddr == "" && c.GRPCAddr == "" {
+		log.Fatal("httpAddr or grpcAddr is required")
+	}
+
+	errs := make(chan error, 2)
+
+	if c.HTTPAddr != "" {
+		go func() {
+			log.Println("http listen on", c.HTTPAddr)
+			errs <- http.ListenAndServe(c.HTTPAddr, gw)
+		}()
+	}
+
+	if c.GRPCAddr != "" {
+		go func() {
+			lis, err := net.Listen("tcp", c.GRPCAddr)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			s := grpc.NewServer()
+			gw.RegisterGRPC(s)
+			log.Println("grpc listen on", c.GRPCAddr)
+			errs <- s.Serve(lis)
+		}()
+	}
+
+	log.Fatal(<-errs)
+}
@@ -0,0 +1,177 @@
+/*
+小米推送网关
+
+通过 REST 和 gRPC 对外提供 xmpush.Client 的功能, 供其他语言的服务调用
+
+	gw, err := gateway.New(client, []gateway.Caller{
+		{Name: "order-service", Token: "token", RatePerSecond: 10, Burst: 20},
+	})
+
+	// REST: POST /v1/{method}, 请求头 Authorization: Bearer {token}
+	http.Handle("/v1/", gw)
+
+	// gRPC: /xmpush.gateway.PushGateway/{Method}, metadata authorization: Bearer {token}
+	gw.RegisterGRPC(grpcServer)
+
+所有接口的请求和返回均为 json 对象, 返回格式为
+
+	{"code": 0, "message": "", "data": {}}
+*/
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/xinpianchang/xmpush"
+)
+
+// 返回码
+const (
+	CodeOK           = 0
+	CodeBadRequest   = 400 // 请求参数错误
+	CodeUnauthorized = 401 // token 错误
+	CodeNotFound     = 404 // 未知的方法
+	CodeRateLimited  = 429 // 超过调用频率限制
+	CodeUpstream     = 502 // 调用小米推送失败
+)
+
+// 网关调用方
+type Caller struct {
+	Name          string  `json:"name"`
+	Token         string  `json:"token"`
+	RatePerSecond float64 `json:"ratePerSecond"` // 每秒调用次数, 0 为不限制
+	Burst         int     `json:"burst"`         // 允许的突发调用次数, 默认与 RatePerSecond 相同
+}
+
+// 网关返回
+type Response struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// 网关错误
+type Error struct {
+	Code    int
+	Message string
+	Details interface{}
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("gateway error %d: %s", e.Code, e.Message)
+}
+
+func badRequest(err error) *Error {
+	e := &Error{Code: CodeBadRequest, Message: err.Error()}
+	switch v := err.(type) {
+	case *xmpush.ValidationError:
+		e.Details = v.Errors
+	case *xmpush.ComplianceError:
+		e.Details = v
+	}
+	return e
+}
+
+type method func(client *xmpush.Client, body []byte) (interface{}, error)
+
+func New(client *xmpush.Client, callers []Caller) (*Gateway, error) {
+	if client == nil {
+		return nil, errors.New("client can't nil")
+	}
+
+	g := &Gateway{
+		client:  client,
+		callers: make(map[string]*caller, len(callers)),
+	}
+
+	for _, c := range callers {
+		if c.Name == "" || c.Token == "" {
+			return nil, errors.New("caller name and token can't empty")
+		}
+		if _, ok := g.callers[c.Token]; ok {
+			return nil, fmt.Errorf("duplicate token for caller %s", c.Name)
+		}
+		g.callers[c.Token] = &caller{
+			Caller:  c,
+			limiter: newRateLimiter(c.RatePerSecond, c.Burst),
+		}
+	}
+
+	return g, nil
+}
+
+type Gateway struct {
+	client  *xmpush.Client
+	callers map[string]*caller
+}
+
+type caller struct {
+	Caller
+	limiter *rateLimiter
+}
+
+// 验证 token 并调用 name 对应的方法
+func (g *Gateway) call(token string, name string, body []byte) (interface{}, *Error) {
+	c, ok := g.callers[token]
+	if !ok || token == "" {
+		return nil, &Error{Code: CodeUnauthorized, Message: "invalid token"}
+	}
+
+	if !c.limiter.allow() {
+		return nil, &Error{Code: CodeRateLimited, Message: fmt.Sprintf("caller %s rate limited", c.Name)}
+	}
+
+	m, ok := methods[name]
+	if !ok {
+		return nil, &Error{Code: CodeNotFound, Message: fmt.Sprintf("unknown method %s", name)}
+	}
+
+	data, err := m(g.client, body)
+	if err != nil {
+		switch e := err.(type) {
+		case *Error:
+			return nil, e
+		case *xmpush.ComplianceError:
+			return nil, badRequest(e)
+		}
+		return nil, &Error{Code: CodeUpstream, Message: err.Error()}
+	}
+
+	// 小米推送返回 code 不为 0 时同样视为上游错误
+	if r, ok := data.(apiResult); ok {
+		if result := r.APIResult(); result != nil && result.Code != 0 {
+			return nil, &Error{
+				Code:    CodeUpstream,
+				Message: fmt.Sprintf("xiaomi push API code %d, %s", result.Code, result.Description),
+				Details: result,
+			}
+		}
+	}
+	return data, nil
+}
+
+// 各种小米推送返回都包含 xmpush.Result
+type apiResult interface {
+	APIResult() *xmpush.Result
+}
+
+func bearerToken(authorization string) string {
+	const prefix = "Bearer "
+	if len(authorization) > len(prefix) && strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return strings.TrimSpace(authorization[len(prefix):])
+	}
+	return ""
+}
+
+func decode(body []byte, v interface{}) error {
+	if len(body) == 0 {
+		body = []byte("{}")
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return badRequest(fmt.Errorf("invalid json: %v", err))
+	}
+	return nil
+}
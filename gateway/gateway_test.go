@@ -0,0 +1,176 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xinpianchang/xmpush"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func newTestGateway(t *testing.T) *Gateway {
+	client, err := xmpush.NewClient("appSecret", "com.server.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gw, err := New(client, []Caller{
+		{Name: "test", Token: "token", RatePerSecond: 1, Burst: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gw
+}
+
+func post(gw *Gateway, path string, token string, body string) (int, *Response) {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	gw.ServeHTTP(w, req)
+
+	var res Response
+	json.Unmarshal(w.Body.Bytes(), &res)
+	return w.Code, &res
+}
+
+func TestGateway_ServeHTTP(t *testing.T) {
+	gw := newTestGateway(t)
+
+	if status, res := post(gw, "/v1/send", "", `{}`); status != http.StatusUnauthorized || res.Code != CodeUnauthorized {
+		t.Fatal(status, res)
+	}
+
+	if status, res := post(gw, "/v1/unknown", "token", `{}`); status != http.StatusNotFound || res.Code != CodeNotFound {
+		t.Fatal(status, res)
+	}
+
+	body := `{"target":{"type":"regid","values":["regid_1"]},"message":{"title":"","description":"d","notify_type":1}}`
+	status, res := post(gw, "/v1/send", "token", body)
+	if status != http.StatusBadRequest || res.Code != CodeBadRequest || res.Data == nil {
+		t.Fatal(status, res)
+	}
+
+	if status, res := post(gw, "/v1/send", "token", `{}`); status != http.StatusTooManyRequests || res.Code != CodeRateLimited {
+		t.Fatal(status, res)
+	}
+}
+
+func TestGateway_SendBadRequest(t *testing.T) {
+	client, err := xmpush.NewClient("appSecret", "com.server.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw, err := New(client, []Caller{{Name: "test", Token: "token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	regIds := make([]string, 1001)
+	for i := range regIds {
+		regIds[i] = fmt.Sprintf("regid_%d", i)
+	}
+	tooMany, _ := json.Marshal(regIds)
+
+	message := `{"title":"t","description":"d","notify_type":1}`
+	bodies := []string{
+		`{"target":{"type":"regid","values":` + string(tooMany) + `},"message":` + message + `}`,
+		`{"target":{"type":"regid","values":["r"]},"message":{"title":"t","description":"d","restricted_package_name":"com.other"}}`,
+		`{"target":{"type":"regid","values":["r"]},"message":{"title":"t","description":"d","model":"MI 9"}}`,
+		`{"target":{"type":"topics","values":["a"]},"message":` + message + `}`,
+	}
+
+	for _, body := range bodies {
+		if status, res := post(gw, "/v1/send", "token", body); status != http.StatusBadRequest || res.Code != CodeBadRequest {
+			t.Fatal(status, res)
+		}
+	}
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestGateway_Upstream(t *testing.T) {
+	client, err := xmpush.NewClient("appSecret", "com.server.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetHTTPClient(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"result":"error","code":22006,"description":"invalid appSecret"}`)),
+		}, nil
+	})})
+
+	gw, err := New(client, []Caller{{Name: "test", Token: "token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bodies := map[string]string{
+		"/v1/send":      `{"target":{"type":"regid","values":["r"]},"message":{"title":"t","description":"d"}}`,
+		"/v1/subscribe": `{"regIds":["r"],"topic":"news"}`,
+		"/v1/recall":    `{"messageId":"msg"}`,
+	}
+	for path, body := range bodies {
+		status, res := post(gw, path, "token", body)
+		data, _ := res.Data.(map[string]interface{})
+		if status != http.StatusBadGateway || res.Code != CodeUpstream || data["code"] != float64(22006) ||
+			!strings.Contains(res.Message, "invalid appSecret") {
+			t.Fatal(path, status, res)
+		}
+	}
+}
+
+func TestGateway_RegisterGRPC(t *testing.T) {
+	gw := newTestGateway(t)
+
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	gw.RegisterGRPC(s)
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	in, _ := structpb.NewStruct(map[string]interface{}{})
+	out := new(structpb.Struct)
+
+	err = conn.Invoke(context.Background(), "/xmpush.gateway.PushGateway/Send", in, out)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatal(err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer token")
+	err = conn.Invoke(ctx, "/xmpush.gateway.PushGateway/ScheduleExist", in, out)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,114 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// gRPC 服务名, 定义见 pushgateway.proto
+const grpcServiceName = "xmpush.gateway.PushGateway"
+
+// 注册 gRPC 服务
+//
+// 方法的请求和返回均为 google.protobuf.Struct, 字段与 REST 接口的 json 相同,
+// 方法名为 REST 方法名的驼峰形式, 如 invalid_regids 为 InvalidRegids
+func (g *Gateway) RegisterGRPC(s *grpc.Server) {
+	desc := grpc.ServiceDesc{
+		ServiceName: grpcServiceName,
+		HandlerType: (*interface{})(nil),
+		Metadata:    "pushgateway.proto",
+	}
+
+	for name := range methods {
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{
+			MethodName: grpcMethodName(name),
+			Handler:    g.grpcHandler(name),
+		})
+	}
+
+	s.RegisterService(&desc, g)
+}
+
+func grpcMethodName(name string) string {
+	parts := strings.Split(name, "_")
+	for i, part := range parts {
+		parts[i] = strings.ToUpper(part[:1]) + part[1:]
+	}
+	return strings.Join(parts, "")
+}
+
+func (g *Gateway) grpcHandler(name string) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		in := new(structpb.Struct)
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return g.grpcCall(ctx, name, req.(*structpb.Struct))
+		}
+
+		if interceptor == nil {
+			return handler(ctx, in)
+		}
+
+		info := &grpc.UnaryServerInfo{
+			Server:     srv,
+			FullMethod: "/" + grpcServiceName + "/" + grpcMethodName(name),
+		}
+		return interceptor(ctx, in, info, handler)
+	}
+}
+
+func (g *Gateway) grpcCall(ctx context.Context, name string, in *structpb.Struct) (*structpb.Struct, error) {
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			token = bearerToken(values[0])
+		}
+	}
+
+	body, err := in.MarshalJSON()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	data, e := g.call(token, name, body)
+	if e != nil {
+		return nil, status.Error(grpcCode(e.Code), e.Message)
+	}
+
+	// 转换为 json 对象
+	raw, err := json.Marshal(&Response{Code: CodeOK, Data: data})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	out := new(structpb.Struct)
+	if err := out.UnmarshalJSON(raw); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return out, nil
+}
+
+func grpcCode(code int) codes.Code {
+	switch code {
+	case CodeBadRequest:
+		return codes.InvalidArgument
+	case CodeUnauthorized:
+		return codes.Unauthenticated
+	case CodeNotFound:
+		return codes.Unimplemented
+	case CodeRateLimited:
+		return codes.ResourceExhausted
+	default:
+		return codes.Unavailable
+	}
+}
@@ -0,0 +1,63 @@
+package gateway
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	httpPathPrefix = "/v1/"
+	maxBodySize    = 1 << 20
+)
+
+// REST 接口, POST /v1/{method}
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeResponse(w, http.StatusMethodNotAllowed, &Response{Code: CodeBadRequest, Message: "method not allowed"})
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.Path, httpPathPrefix) {
+		writeError(w, &Error{Code: CodeNotFound, Message: "not found"})
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		writeError(w, &Error{Code: CodeBadRequest, Message: err.Error()})
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, httpPathPrefix)
+	data, e := g.call(bearerToken(r.Header.Get("Authorization")), name, body)
+	if e != nil {
+		writeError(w, e)
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &Response{Code: CodeOK, Data: data})
+}
+
+func writeError(w http.ResponseWriter, e *Error) {
+	status := http.StatusBadGateway
+	switch e.Code {
+	case CodeBadRequest:
+		status = http.StatusBadRequest
+	case CodeUnauthorized:
+		status = http.StatusUnauthorized
+	case CodeNotFound:
+		status = http.StatusNotFound
+	case CodeRateLimited:
+		status = http.StatusTooManyRequests
+	}
+
+	writeResponse(w, status, &Response{Code: e.Code, Message: e.Message, Data: e.Details})
+}
+
+func writeResponse(w http.ResponseWriter, status int, response *Response) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,276 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/xinpianchang/xmpush"
+)
+
+// 网关提供的方法, key 为 REST 路径 /v1/{key} 和 gRPC 方法名
+var methods = map[string]method{
+	"send":            send,
+	"recall":          recall,
+	"stats":           stats,
+	"trace":           trace,
+	"subscribe":       subscribe,
+	"unsubscribe":     unsubscribe,
+	"invalid_regids":  invalidRegIds,
+	"regid_alias":     regIdAlias,
+	"regid_topics":    regIdTopics,
+	"schedule_exist":  scheduleExist,
+	"schedule_delete": scheduleDelete,
+}
+
+var targetTypes = map[string]xmpush.TargetType{
+	"regid":   xmpush.TargetRegId,
+	"alias":   xmpush.TargetAlias,
+	"account": xmpush.TargetAccount,
+	"topic":   xmpush.TargetTopic,
+	"topics":  xmpush.TargetTopics,
+	"all":     xmpush.TargetAll,
+}
+
+type Target struct {
+	Type    string   `json:"type"` // regid, alias, account, topic, topics, all
+	Values  []string `json:"values,omitempty"`
+	TopicOP string   `json:"topicOp,omitempty"`
+}
+
+// send 的请求
+type SendRequest struct {
+	Target  Target          `json:"target"`
+	Message *xmpush.Message `json:"message"`
+}
+
+func send(client *xmpush.Client, body []byte) (interface{}, error) {
+	// 未设置的字段使用 xmpush.NewMessage 的默认值, 与 Go 调用方一致
+	req := SendRequest{Message: xmpush.NewMessage("", "")}
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+
+	targetType, ok := targetTypes[req.Target.Type]
+	if !ok {
+		return nil, badRequest(fmt.Errorf("unknown target type %q", req.Target.Type))
+	}
+
+	if targetType != xmpush.TargetAll && len(req.Target.Values) == 0 {
+		return nil, badRequest(errors.New("target values can't empty"))
+	}
+
+	if req.Message == nil {
+		return nil, badRequest(errors.New("message can't empty"))
+	}
+
+	target := xmpush.Target{
+		Type:    targetType,
+		Values:  req.Target.Values,
+		TopicOP: xmpush.TopicOP(req.Target.TopicOP),
+	}
+
+	// 在调用小米推送前检查目标数量、package 等, 参数错误返回 400 而不是 502
+	if err := client.CheckSend(req.Message, target); err != nil {
+		return nil, badRequest(err)
+	}
+
+	return client.Send(req.Message, target)
+}
+
+// recall 的请求, topic 不为空时撤回 topic 消息
+type RecallRequest struct {
+	MessageID string `json:"messageId"`
+	Topic     string `json:"topic,omitempty"`
+}
+
+func recall(client *xmpush.Client, body []byte) (interface{}, error) {
+	var req RecallRequest
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+
+	if req.MessageID == "" {
+		return nil, badRequest(errors.New("messageId can't empty"))
+	}
+
+	if req.Topic != "" {
+		return client.RecallTopicMessage(req.MessageID, req.Topic)
+	}
+	return client.RecallMessage(req.MessageID)
+}
+
+// stats 的请求, 日期格式为 yyyyMMdd
+type StatsRequest struct {
+	Start       string `json:"start"`
+	End         string `json:"end"`
+	PackageName string `json:"packageName,omitempty"`
+}
+
+func stats(client *xmpush.Client, body []byte) (interface{}, error) {
+	var req StatsRequest
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+
+	if len(req.Start) != 8 || len(req.End) != 8 {
+		return nil, badRequest(errors.New("start and end should be yyyyMMdd"))
+	}
+
+	if req.PackageName != "" {
+		c, err := client.ForPackage(req.PackageName)
+		if err != nil {
+			return nil, badRequest(err)
+		}
+		client = c
+	}
+
+	return client.Stats(req.Start, req.End)
+}
+
+// trace 的请求, 依次按 messageId, jobKey, 时间范围 (毫秒) 查询
+type TraceRequest struct {
+	MessageID string `json:"messageId,omitempty"`
+	JobKey    string `json:"jobKey,omitempty"`
+	Begin     int64  `json:"begin,omitempty"`
+	End       int64  `json:"end,omitempty"`
+}
+
+func trace(client *xmpush.Client, body []byte) (interface{}, error) {
+	var req TraceRequest
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case req.MessageID != "":
+		return client.GetMessageStatusByMessageId(req.MessageID)
+	case req.JobKey != "":
+		return client.GetMessageStatusByJobKey(req.JobKey)
+	case req.Begin > 0 && req.End > req.Begin:
+		return client.GetMessageStatusByRange(req.Begin, req.End)
+	default:
+		return nil, badRequest(errors.New("need messageId, jobKey or begin/end"))
+	}
+}
+
+// subscribe, unsubscribe 的请求, regIds 和 aliases 只能设置一个
+type SubscribeRequest struct {
+	RegIds   []string `json:"regIds,omitempty"`
+	Aliases  []string `json:"aliases,omitempty"`
+	Topic    string   `json:"topic"`
+	Category string   `json:"category,omitempty"`
+}
+
+func (r *SubscribeRequest) validate() error {
+	if r.Topic == "" {
+		return badRequest(errors.New("topic can't empty"))
+	}
+	if (len(r.RegIds) == 0) == (len(r.Aliases) == 0) {
+		return badRequest(errors.New("need one of regIds, aliases"))
+	}
+	return nil
+}
+
+func subscribe(client *xmpush.Client, body []byte) (interface{}, error) {
+	var req SubscribeRequest
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+
+	if len(req.RegIds) > 0 {
+		return client.SubscribeForRegId(&req.RegIds, req.Topic, req.Category)
+	}
+	return client.SubscribeForAlias(&req.Aliases, req.Topic, req.Category)
+}
+
+func unsubscribe(client *xmpush.Client, body []byte) (interface{}, error) {
+	var req SubscribeRequest
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+
+	if len(req.RegIds) > 0 {
+		return client.UnsubscribeForRegId(&req.RegIds, req.Topic, req.Category)
+	}
+	return client.UnsubscribeForAlias(&req.Aliases, req.Topic, req.Category)
+}
+
+func invalidRegIds(client *xmpush.Client, body []byte) (interface{}, error) {
+	return client.FetchInvalidRegIds()
+}
+
+// regid_alias, regid_topics 的请求
+type RegIdRequest struct {
+	RegId string `json:"regId"`
+}
+
+func regIdAlias(client *xmpush.Client, body []byte) (interface{}, error) {
+	var req RegIdRequest
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	if req.RegId == "" {
+		return nil, badRequest(errors.New("regId can't empty"))
+	}
+	return client.GetRegIdAlias(req.RegId)
+}
+
+func regIdTopics(client *xmpush.Client, body []byte) (interface{}, error) {
+	var req RegIdRequest
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	if req.RegId == "" {
+		return nil, badRequest(errors.New("regId can't empty"))
+	}
+	return client.GetRegIdTopic(req.RegId)
+}
+
+// schedule_exist, schedule_delete 的请求, messageId 和 jobKey 只能设置一个
+type ScheduleRequest struct {
+	MessageID string `json:"messageId,omitempty"`
+	JobKey    string `json:"jobKey,omitempty"`
+}
+
+func (r *ScheduleRequest) validate() error {
+	if (r.MessageID == "") == (r.JobKey == "") {
+		return badRequest(errors.New("need one of messageId, jobKey"))
+	}
+	return nil
+}
+
+func scheduleExist(client *xmpush.Client, body []byte) (interface{}, error) {
+	var req ScheduleRequest
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+
+	if req.MessageID != "" {
+		return client.ScheduleJobExist(req.MessageID)
+	}
+	return client.ScheduleJobExistByJobKey(req.JobKey)
+}
+
+func scheduleDelete(client *xmpush.Client, body []byte) (interface{}, error) {
+	var req ScheduleRequest
+	if err := decode(body, &req); err != nil {
+		return nil, err
+	}
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+
+	if req.MessageID != "" {
+		return client.ScheduleJobDelete(req.MessageID)
+	}
+	return client.ScheduleJobDeleteByJobKey(req.JobKey)
+}
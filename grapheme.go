@@ -0,0 +1,105 @@
+package xmpush
+
+import (
+	"unicode"
+)
+
+// 截断时使用的省略号
+const ellipsis = "…"
+
+// 按字素簇 (用户看到的一个字符) 切分字符串, 返回每个字素簇开始的位置
+//
+// 处理了组合字符、变体选择符、emoji 肤色、ZWJ 组合 emoji、国旗和 \r\n,
+// 是 Unicode 字素簇规则的近似实现
+func graphemeBoundaries(s string) []int {
+	var boundaries []int
+	var prev rune
+	regionalCount := 0
+	joined := false
+
+	for i, r := range s {
+		extend := false
+		switch {
+		case i == 0:
+		case prev == '\r' && r == '\n':
+			extend = true
+		case joined:
+			extend = true
+		case isGraphemeExtend(r):
+			extend = true
+		case isRegionalIndicator(r) && isRegionalIndicator(prev) && regionalCount%2 == 1:
+			extend = true
+		}
+
+		if !extend {
+			boundaries = append(boundaries, i)
+			regionalCount = 0
+		}
+
+		if isRegionalIndicator(r) {
+			regionalCount++
+		}
+		joined = r == '\u200d'
+		prev = r
+	}
+
+	return boundaries
+}
+
+func isGraphemeExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		r == '\u200d' ||
+		(r >= 0xfe00 && r <= 0xfe0f) || // 变体选择符
+		(r >= 0xe0100 && r <= 0xe01ef) ||
+		(r >= 0x1f3fb && r <= 0x1f3ff) || // emoji 肤色
+		(r >= 0xe0020 && r <= 0xe007f) // emoji tag
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+// 文本长度, 与小米服务端一致按 UTF-16 编码单元计算,
+// 中英文字符均计算为 1, emoji 等补充平面字符计算为 2, 组合 emoji 为各部分之和
+func textLength(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// 超过 max 时截断, 截断后以省略号结尾, 总长度不超过 max
+//
+// 长度按 textLength 计算, 只在字素簇边界截断, 不会拆分 emoji 等组合字符
+func truncateText(s string, max int) string {
+	if textLength(s) <= max {
+		return s
+	}
+	if max <= 0 {
+		return ""
+	}
+
+	budget := max - textLength(ellipsis)
+	boundaries := graphemeBoundaries(s)
+
+	end, n := 0, 0
+	for i, start := range boundaries {
+		next := len(s)
+		if i+1 < len(boundaries) {
+			next = boundaries[i+1]
+		}
+
+		n += textLength(s[start:next])
+		if n > budget {
+			break
+		}
+		end = next
+	}
+
+	return s[:end] + ellipsis
+}
@@ -0,0 +1,69 @@
+package xmpush
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGraphemeBoundaries(t *testing.T) {
+	cases := map[string]int{
+		"hello":  5,
+		"你好世界":   4,
+		"é":     1,
+		"👍🏽":     1,
+		"👨‍👩‍👧":  1,
+		"🇨🇳🇺🇸":   2,
+		"❤️!":    2,
+		"a\r\nb": 3,
+		"小米推送👨‍👩‍👧 🇨🇳": 7,
+	}
+
+	for s, count := range cases {
+		if n := len(graphemeBoundaries(s)); n != count {
+			t.Fatalf("%q count %d, should be %d", s, n, count)
+		}
+	}
+}
+
+func TestTextLength(t *testing.T) {
+	cases := map[string]int{
+		"hello": 5,
+		"你好世界":  4,
+		"👍":     2,
+		"👨‍👩‍👧": 8,
+	}
+
+	for s, length := range cases {
+		if n := textLength(s); n != length {
+			t.Fatalf("%q length %d, should be %d", s, n, length)
+		}
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	if s := truncateText("你好👨‍👩‍👧世界", 11); s != "你好👨‍👩‍👧…" {
+		t.Fatalf("%q", s)
+	}
+
+	// 不拆分组合 emoji
+	if s := truncateText("你好👨‍👩‍👧世界", 10); s != "你好…" {
+		t.Fatalf("%q", s)
+	}
+
+	if s := truncateText("你好", 2); s != "你好" {
+		t.Fatalf("%q", s)
+	}
+
+	family := strings.Repeat("👨‍👩‍👧", 50)
+	if err := NewMessage(family, "description").Validate(); err == nil {
+		t.Fatal("expect title too long")
+	}
+
+	s := truncateText(family, MaxTitleLength)
+	if textLength(s) > MaxTitleLength || s != strings.Repeat("👨‍👩‍👧", 6)+"…" {
+		t.Fatalf("%q", s)
+	}
+	if err := NewMessage(s, "description").Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
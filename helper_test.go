@@ -0,0 +1,36 @@
+package xmpush
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// 不访问网络的 client, handler 根据请求返回 json 响应
+func newStubClient(t *testing.T, handler func(req *http.Request, form url.Values) string) *Client {
+	c, err := NewClient("secret", "com.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.SetHTTPClient(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		form := req.URL.Query()
+		if req.Body != nil {
+			body, _ := ioutil.ReadAll(req.Body)
+			form, _ = url.ParseQuery(string(body))
+		}
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(handler(req, form))),
+		}, nil
+	})})
+	return c
+}
@@ -0,0 +1,91 @@
+package xmpush
+
+import (
+	"errors"
+)
+
+const (
+	hybridRegIdURL = "/v2/hybrid/message/regid"
+	hybridAliasURL = "/v2/hybrid/message/alias"
+	hybridTopicURL = "/v2/hybrid/message/topic"
+	hybridAllURL   = "/v2/hybrid/message/all"
+)
+
+func NewHybridMessage(title string, description string, hybridPn string) *HybridMessage {
+	return &HybridMessage{
+		Message:  NewMessage(title, description),
+		HybridPn: hybridPn,
+	}
+}
+
+// 快应用消息
+type HybridMessage struct {
+	*Message
+	HybridPn    string // 快应用包名
+	HybridPath  string // 点击后打开的快应用页面, 为空时打开首页
+	HybridDebug bool   // 发送到快应用调试器
+}
+
+func (m *HybridMessage) SetHybridPath(path string) *HybridMessage {
+	m.HybridPath = path
+	return m
+}
+
+func (m *HybridMessage) EnableDebug() *HybridMessage {
+	m.HybridDebug = true
+	return m
+}
+
+func (m *HybridMessage) DisableDebug() *HybridMessage {
+	m.HybridDebug = false
+	return m
+}
+
+func (m *HybridMessage) toMessage() (*Message, error) {
+	if m == nil || m.Message == nil {
+		return nil, errors.New("message can't nil")
+	}
+
+	if m.HybridPn == "" {
+		return nil, errors.New("hybrid_pn can't empty")
+	}
+
+	message := m.Message.Clone()
+	message.AddExtra("hybrid_pn", m.HybridPn)
+	if m.HybridPath != "" {
+		message.AddExtra("hybrid_path", m.HybridPath)
+	}
+	if m.HybridDebug {
+		message.AddExtra("hybrid_debug", "1")
+	}
+	return message, nil
+}
+
+// 向 regId 发送快应用消息
+func (c *Client) SendHybridToRegId(hybrid *HybridMessage, regId *[]string) (*SendResult, error) {
+	return c.sendHybrid(hybrid, RegIdTarget(stringValues(regId)...))
+}
+
+// 向 alias 发送快应用消息
+func (c *Client) SendHybridToAlias(hybrid *HybridMessage, alias *[]string) (*SendResult, error) {
+	return c.sendHybrid(hybrid, AliasTarget(stringValues(alias)...))
+}
+
+// 向 topic 发送快应用消息
+func (c *Client) SendHybridToTopic(hybrid *HybridMessage, topic string) (*SendResult, error) {
+	return c.sendHybrid(hybrid, TopicTarget(topic))
+}
+
+// 向 所有设备 发送快应用消息
+func (c *Client) SendHybridToAll(hybrid *HybridMessage) (*SendResult, error) {
+	return c.sendHybrid(hybrid, AllTarget())
+}
+
+func (c *Client) sendHybrid(hybrid *HybridMessage, target Target) (*SendResult, error) {
+	message, err := hybrid.toMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.dispatch(&SendRequest{Target: target, Message: message, Hybrid: true})
+}
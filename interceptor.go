@@ -0,0 +1,201 @@
+package xmpush
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// 发送请求, 所有发送方法在编码前都会转换为 SendRequest 并经过拦截器
+//
+// Message 和 Messages 中的消息均为副本, 拦截器可以直接修改, 不影响调用方
+type SendRequest struct {
+	Target   Target            // 发送目标, 多条消息时只有 Type
+	Message  *Message          // 单条消息
+	Messages []TargetedMessage // SendTargetedMessage 的多条消息
+	Hybrid   bool              // 快应用消息
+}
+
+// 执行发送请求
+type SendHandler func(req *SendRequest) (*SendResult, error)
+
+// 发送拦截器, 调用 next 继续发送, 可以在调用前修改请求, 调用后处理结果,
+// 不调用 next 直接返回错误可以拒绝发送
+type SendInterceptor func(req *SendRequest, next SendHandler) (*SendResult, error)
+
+// 添加发送拦截器, 按添加顺序执行, 先添加的在外层
+func (c *Client) Use(interceptors ...SendInterceptor) {
+	c.interceptors = append(c.interceptors[:len(c.interceptors):len(c.interceptors)], interceptors...)
+}
+
+// 复制消息和目标, 之后的修改不影响调用方
+func (req *SendRequest) copy() error {
+	if req.Messages == nil {
+		if req.Message == nil {
+			return errors.New("message can't nil")
+		}
+		req.Message = req.Message.Clone()
+	} else {
+		messages := make([]TargetedMessage, len(req.Messages))
+		for i, m := range req.Messages {
+			if m.message == nil {
+				return errors.New("message can't nil")
+			}
+			messages[i] = *NewTargetedMessage(m.message.Clone(), m.target, m.targetType)
+		}
+		req.Messages = messages
+	}
+	if req.Target.Values != nil {
+		req.Target.Values = append([]string(nil), req.Target.Values...)
+	}
+	return nil
+}
+
+// 检查消息和目标能否发送, 包括消息检查、内容检查、目标数量和 package, 不发送请求也不经过拦截器
+func (c *Client) CheckSend(message *Message, target Target) error {
+	req := &SendRequest{Target: target, Message: message}
+	if err := req.copy(); err != nil {
+		return err
+	}
+
+	_, _, err := c.buildSend(req)
+	return err
+}
+
+// 复制请求后依次经过拦截器发送
+func (c *Client) dispatch(req *SendRequest) (*SendResult, error) {
+	if err := req.copy(); err != nil {
+		return nil, err
+	}
+
+	handler := c.send
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := c.interceptors[i], handler
+		handler = func(req *SendRequest) (*SendResult, error) {
+			return interceptor(req, next)
+		}
+	}
+
+	return handler(req)
+}
+
+// 编码并发送请求
+func (c *Client) send(req *SendRequest) (*SendResult, error) {
+	var api string
+	var param *url.Values
+	var err error
+
+	if req.Messages != nil {
+		api, param, err = c.buildTargetedSend(req)
+	} else {
+		api, param, err = c.buildSend(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return c.postSend(api, param)
+}
+
+func (c *Client) buildSend(req *SendRequest) (string, *url.Values, error) {
+	target := req.Target
+	if req.Hybrid && (target.Type == TargetAccount || target.Type == TargetTopics) {
+		return "", nil, fmt.Errorf("hybrid message not support target type %d", target.Type)
+	}
+
+	switch target.Type {
+	case TargetRegId:
+		param, err := c.buildParam(req.Message, "registration_id", &target.Values)
+		return pick(req.Hybrid, hybridRegIdURL, regIdURL), param, err
+	case TargetAlias:
+		param, err := c.buildParam(req.Message, "alias", &target.Values)
+		return pick(req.Hybrid, hybridAliasURL, aliasURL), param, err
+	case TargetAccount:
+		param, err := c.buildParam(req.Message, "user_account", &target.Values)
+		return accountURL, param, err
+	case TargetTopic:
+		if len(target.Values) != 1 {
+			return "", nil, errors.New("topic target should have one topic")
+		}
+		param, err := c.messageToForm(req.Message)
+		if err != nil {
+			return "", nil, err
+		}
+		param.Add("topic", target.Values[0])
+		return pick(req.Hybrid, hybridTopicURL, TopicURL), param, nil
+	case TargetTopics:
+		param, err := c.buildTopicsParam(req.Message, target.Values, target.TopicOP)
+		return TopicOpURL, param, err
+	case TargetAll:
+		param, err := c.messageToForm(req.Message)
+		return pick(req.Hybrid, hybridAllURL, allURL), param, err
+	default:
+		return "", nil, fmt.Errorf("unknown target type %d", target.Type)
+	}
+}
+
+func (c *Client) buildTopicsParam(message *Message, topics []string, topicOP TopicOP) (*url.Values, error) {
+	param, err := c.messageToForm(message)
+	if err != nil {
+		return nil, err
+	}
+
+	tc := len(topics)
+	if tc < 2 || tc > 5 {
+		return nil, errors.New("topics count should between 1 and 5")
+	}
+
+	if topicOP == "" {
+		topicOP = TopicOPUnion
+	}
+
+	if topicOP != TopicOPUnion &&
+		topicOP != TopicOPIntersection &&
+		topicOP != TopicOPExcept {
+		return nil, errors.New("unknown topic option")
+	}
+
+	param.Add("topics", strings.Join(topics, ";$;"))
+	param.Add("topic_op", string(topicOP))
+	return param, nil
+}
+
+func (c *Client) buildTargetedSend(req *SendRequest) (string, *url.Values, error) {
+	if len(req.Messages) == 0 {
+		return "", nil, errors.New("messages can't empty")
+	}
+	if req.Hybrid {
+		return "", nil, errors.New("hybrid message not support targeted messages")
+	}
+
+	param, err := c.buildTargetedMessageParam(&req.Messages)
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch targetType := req.Messages[0].targetType; targetType {
+	case TargetRegId:
+		return multiRegIdURL, param, nil
+	case TargetAlias:
+		return multiAliasURL, param, nil
+	case TargetAccount:
+		return multiAccountURL, param, nil
+	default:
+		return "", nil, fmt.Errorf("unknown target type %d", targetType)
+	}
+}
+
+func pick(hybrid bool, hybridAPI string, api string) string {
+	if hybrid {
+		return hybridAPI
+	}
+	return api
+}
+
+func stringValues(values *[]string) []string {
+	if values == nil {
+		return nil
+	}
+	return *values
+}
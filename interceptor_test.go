@@ -0,0 +1,72 @@
+package xmpush
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestClient_Use(t *testing.T) {
+	c, err := NewClient("secret", "com.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var form url.Values
+	var path string
+	c.SetHTTPClient(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := ioutil.ReadAll(req.Body)
+		form, _ = url.ParseQuery(string(body))
+		path = req.URL.Path
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"code":0,"data":{"id":"msg"}}`)),
+		}, nil
+	})})
+
+	var calls []string
+	c.Use(func(req *SendRequest, next SendHandler) (*SendResult, error) {
+		calls = append(calls, "outer")
+		req.Message.AddExtra("ab", "b")
+		result, err := next(req)
+		if err == nil {
+			calls = append(calls, "result "+result.Data.ID)
+		}
+		return result, err
+	}, func(req *SendRequest, next SendHandler) (*SendResult, error) {
+		calls = append(calls, "inner")
+		if req.Target.Type == TargetAll {
+			return nil, errors.New("broadcast not allowed")
+		}
+		req.Message.Title = "[audit] " + req.Message.Title
+		return next(req)
+	})
+
+	message := NewMessage("title", "description")
+	if _, err := c.SendToRegId(message, &[]string{"r1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if path != regIdURL || form.Get("title") != "[audit] title" || form.Get("extra.ab") != "b" ||
+		form.Get("registration_id") != "r1" || message.Title != "title" || message.Extra["ab"] != "" {
+		t.Fatal(path, form, message)
+	}
+	if strings.Join(calls, ",") != "outer,inner,result msg" {
+		t.Fatal(calls)
+	}
+
+	path = ""
+	if _, err := c.SendToAll(message); err == nil || path != "" {
+		t.Fatal("expect vetoed", err, path)
+	}
+
+	if _, err := c.SendHybridToTopic(NewHybridMessage("title", "description", "com.hybrid"), "news"); err != nil {
+		t.Fatal(err)
+	}
+	if path != hybridTopicURL || form.Get("extra.hybrid_pn") != "com.hybrid" || form.Get("topic") != "news" {
+		t.Fatal(path, form)
+	}
+}
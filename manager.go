@@ -0,0 +1,270 @@
+package xmpush
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 单个小米应用的配置
+type AppConfig struct {
+	AppID        string   `json:"appId"`
+	AppSecret    string   `json:"appSecret"`
+	PackageNames []string `json:"packageName"`
+	UseSandbox   bool     `json:"useSandbox,omitempty"`
+}
+
+func (a *AppConfig) equal(o *AppConfig) bool {
+	if a.AppID != o.AppID ||
+		a.AppSecret != o.AppSecret ||
+		a.UseSandbox != o.UseSandbox ||
+		len(a.PackageNames) != len(o.PackageNames) {
+		return false
+	}
+
+	for i := range a.PackageNames {
+		if a.PackageNames[i] != o.PackageNames[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// 应用配置来源, Reload 时重新调用 Load
+type ConfigSource interface {
+	Load() ([]AppConfig, error)
+}
+
+// 固定的应用配置
+type StaticConfigSource []AppConfig
+
+func (s StaticConfigSource) Load() ([]AppConfig, error) {
+	return s, nil
+}
+
+// 从 json 文件读取应用配置, 文件内容为 AppConfig 数组
+//
+//	[
+//	  {
+//	    "appId": "app_1",
+//	    "appSecret": "appSecret",
+//	    "packageName": ["com.server.example"]
+//	  }
+//	]
+type FileConfigSource string
+
+func (s FileConfigSource) Load() ([]AppConfig, error) {
+	bytes, err := ioutil.ReadFile(string(s))
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []AppConfig
+	err = json.Unmarshal(bytes, &configs)
+	if err != nil {
+		return nil, err
+	}
+
+	return configs, nil
+}
+
+// 管理多个小米应用的 client, 以 appId 区分
+//
+// 所有 client 共享同一个 http.Client, 配置可以通过 Reload 或 WatchReload 热更新
+func NewClientManager(source ConfigSource) (*ClientManager, error) {
+	if source == nil {
+		return nil, errors.New("config source can't nil")
+	}
+
+	m := &ClientManager{
+		source: source,
+		httpClient: &http.Client{
+			Timeout:   20 * time.Second,
+			Transport: http.DefaultTransport.(*http.Transport).Clone(),
+		},
+		log:     &nopeLogger{},
+		configs: make(map[string]AppConfig),
+		clients: make(map[string]*Client),
+	}
+
+	if err := m.Reload(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+type ClientManager struct {
+	source        ConfigSource
+	httpClient    *http.Client
+	log           logger
+	clientOptions func(appID string, c *Client)
+
+	mu      sync.RWMutex
+	configs map[string]AppConfig
+	clients map[string]*Client
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// 设置 logger, 同时作用于已创建和之后创建的 client
+func (m *ClientManager) SetLogger(logger logger) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.log = logger
+	for _, client := range m.clients {
+		client.SetLogger(logger)
+	}
+}
+
+// 设置 client 的配置函数, 替换之前的设置
+//
+// 会立即作用于已创建的 client, 之后 Reload 创建的 client 也会调用,
+// 用于设置 Use, SetContentChecker, SetIdempotent 等, 避免配置变化后新 client 丢失设置。
+// Client 的设置方法不是并发安全的, 需要在 NewClientManager 之后、获取 client 发送消息
+// 和 WatchReload 之前调用。fn 在持有锁时调用, 不能调用 ClientManager 的方法
+func (m *ClientManager) SetClientOptions(fn func(appID string, c *Client)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clientOptions = fn
+	if fn == nil {
+		return
+	}
+	for id, client := range m.clients {
+		fn(id, client)
+	}
+}
+
+// 获取 appId 对应的 client
+func (m *ClientManager) Client(appID string) (*Client, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	client, ok := m.clients[appID]
+	if !ok {
+		return nil, fmt.Errorf("unknown app id %s", appID)
+	}
+	return client, nil
+}
+
+// 使用 appId 对应的 client 发送消息
+func (m *ClientManager) Send(appID string, message *Message, target Target) (*SendResult, error) {
+	client, err := m.Client(appID)
+	if err != nil {
+		return nil, err
+	}
+	return client.Send(message, target)
+}
+
+// 所有 appId, 按字母排序
+func (m *ClientManager) AppIDs() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := make([]string, 0, len(m.clients))
+	for id := range m.clients {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// 重新加载配置
+//
+// 配置有变化的应用会创建新的 client, 并调用 SetClientOptions 设置的函数,
+// 已删除的应用会被移除, 调用方持有的旧 client 仍然可用。配置有误时保留原有的 client
+func (m *ClientManager) Reload() error {
+	configs, err := m.source.Load()
+	if err != nil {
+		return err
+	}
+
+	loaded := make(map[string]AppConfig, len(configs))
+	for _, config := range configs {
+		if config.AppID == "" {
+			return errors.New("app id can't empty")
+		}
+		if _, ok := loaded[config.AppID]; ok {
+			return fmt.Errorf("duplicate app id %s", config.AppID)
+		}
+		loaded[config.AppID] = config
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	clients := make(map[string]*Client, len(loaded))
+	for id, config := range loaded {
+		if old, ok := m.configs[id]; ok && old.equal(&config) {
+			clients[id] = m.clients[id]
+			continue
+		}
+
+		client, err := NewClient(config.AppSecret, config.PackageNames...)
+		if err != nil {
+			return fmt.Errorf("app %s: %v", id, err)
+		}
+		client.UseSandbox(config.UseSandbox)
+		client.SetHTTPClient(m.httpClient)
+		client.SetLogger(m.log)
+		if m.clientOptions != nil {
+			m.clientOptions(id, client)
+		}
+		clients[id] = client
+
+		m.log.Debugf("client manager load app %s", id)
+	}
+
+	m.configs = loaded
+	m.clients = clients
+	return nil
+}
+
+// 每隔 interval 重新加载一次配置, 直到 Close
+func (m *ClientManager) WatchReload(interval time.Duration) {
+	m.mu.Lock()
+	if m.stop != nil {
+		m.mu.Unlock()
+		return
+	}
+	m.stop = make(chan struct{})
+	stop := m.stop
+	m.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := m.Reload(); err != nil {
+					m.log.Debug("client manager reload error: ", err)
+				}
+			}
+		}
+	}()
+}
+
+// 停止 WatchReload 并关闭空闲连接
+func (m *ClientManager) Close() {
+	m.stopOnce.Do(func() {
+		m.mu.Lock()
+		if m.stop != nil {
+			close(m.stop)
+		}
+		m.mu.Unlock()
+
+		m.httpClient.CloseIdleConnections()
+	})
+}
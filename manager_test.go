@@ -0,0 +1,126 @@
+package xmpush
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type testConfigSource struct {
+	mu      sync.Mutex
+	configs []AppConfig
+}
+
+func (s *testConfigSource) Load() ([]AppConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]AppConfig(nil), s.configs...), nil
+}
+
+func (s *testConfigSource) set(configs ...AppConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.configs = configs
+}
+
+func TestClientManager_Reload(t *testing.T) {
+	source := &testConfigSource{}
+	source.set(
+		AppConfig{AppID: "app_1", AppSecret: "secret_1", PackageNames: []string{"com.a"}},
+		AppConfig{AppID: "app_2", AppSecret: "secret_2", PackageNames: []string{"com.b"}},
+	)
+
+	manager, err := NewClientManager(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	var secrets []string
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		secrets = append(secrets, req.Header.Get("Authorization"))
+		return &http.Response{
+			StatusCode: 200,
+			Body:       ioutil.NopCloser(strings.NewReader(`{"code":0,"data":{"id":"msg"}}`)),
+		}, nil
+	})
+
+	manager.httpClient.Transport = transport
+
+	var configured []string
+	manager.SetClientOptions(func(appID string, c *Client) {
+		configured = append(configured, appID)
+		c.SetTruncate(true)
+	})
+	if len(configured) != 2 {
+		t.Fatal(configured)
+	}
+
+	c1, err := manager.Client("app_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.Client("app_3"); err == nil {
+		t.Fatal("unknown app should error")
+	}
+
+	if _, err := manager.Send("app_1", NewMessage("title", "description"), RegIdTarget("r1")); err != nil {
+		t.Fatal(err)
+	}
+
+	// app_1 不变, app_2 删除, app_3 新增
+	source.set(
+		AppConfig{AppID: "app_1", AppSecret: "secret_1", PackageNames: []string{"com.a"}},
+		AppConfig{AppID: "app_3", AppSecret: "secret_3", PackageNames: []string{"com.c"}},
+	)
+	if err := manager.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if same, _ := manager.Client("app_1"); same != c1 {
+		t.Fatal("unchanged config should keep client")
+	}
+	if ids := manager.AppIDs(); strings.Join(ids, ",") != "app_1,app_3" {
+		t.Fatal(ids)
+	}
+	c3, err := manager.Client("app_3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// app_1 更换 secret, 新 client 仍然使用 SetClientOptions 的设置
+	source.set(AppConfig{AppID: "app_1", AppSecret: "secret_new", PackageNames: []string{"com.a"}})
+	if err := manager.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	c2, _ := manager.Client("app_1")
+	if c2 == c1 || !c2.truncate {
+		t.Fatal("changed config should create configured client")
+	}
+	// Reload 创建的 client 共享同一个 http.Client
+	if c1.client != manager.httpClient || c2.client != manager.httpClient || c3.client != manager.httpClient {
+		t.Fatal("clients should share http client")
+	}
+	if _, err := manager.Send("app_1", NewMessage("title", "description"), RegIdTarget("r1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manager.Send("app_3", NewMessage("title", "description"), RegIdTarget("r1")); err == nil {
+		t.Fatal("removed app should error")
+	}
+
+	if len(configured) != 4 || configured[2] != "app_3" || configured[3] != "app_1" {
+		t.Fatal(configured)
+	}
+	if len(secrets) != 2 || secrets[0] != "key=secret_1" || secrets[1] != "key=secret_new" {
+		t.Fatal(secrets)
+	}
+
+	source.set(AppConfig{AppID: "", AppSecret: "secret"})
+	if err := manager.Reload(); err == nil {
+		t.Fatal("invalid config should error")
+	}
+	if ids := manager.AppIDs(); len(ids) != 1 {
+		t.Fatal("invalid config should keep clients", ids)
+	}
+}
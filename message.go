@@ -1,7 +1,9 @@
 package xmpush
 
 import (
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"strings"
 	"time"
 )
@@ -9,6 +11,9 @@ import (
 var (
 	MaxTimeToSend = time.Hour * 24 * 7
 	MaxTimeToLive = int64(3600 * 1000 * 24 * 7 * 2)
+
+	// SetCollapseKey 使用的命名空间, 不同业务使用不同的命名空间避免 notifyId 冲突
+	CollapseKeyNamespace = ""
 )
 
 func NewMessage(title string, description string) *Message {
@@ -33,6 +38,80 @@ type Message struct {
 	TimeToSend            int64             `json:"time_to_send,omitempty"`
 	NotifyID              int64             `json:"notify_id,omitempty"`
 	Extra                 map[string]string `json:"extra,omitempty"`
+
+	// 广播消息的设备过滤条件, 多个值用逗号分隔
+	Model           string `json:"model,omitempty"`              // 设备型号
+	AppVersion      string `json:"app_version,omitempty"`        // app 版本
+	AppVersionNotIn string `json:"app_version_not_in,omitempty"` // 排除的 app 版本
+	Locale          string `json:"locale,omitempty"`             // 地区, 如 zh_CN
+	LocaleNotIn     string `json:"locale_not_in,omitempty"`      // 排除的地区
+	Connpt          string `json:"connpt,omitempty"`             // 网络类型, 目前只支持 wifi
+
+	strict      bool
+	fieldErrors map[string]error
+}
+
+// 深复制消息, 复制后的消息可以独立修改
+func (m *Message) Clone() *Message {
+	c := *m
+	if m.Extra != nil {
+		c.Extra = make(map[string]string, len(m.Extra))
+		for k, v := range m.Extra {
+			c.Extra[k] = v
+		}
+	}
+	if m.fieldErrors != nil {
+		c.fieldErrors = make(map[string]error, len(m.fieldErrors))
+		for k, v := range m.fieldErrors {
+			c.fieldErrors[k] = v
+		}
+	}
+	return &c
+}
+
+// 以下 With 方法不修改原消息, 返回修改后的副本, 可用于以同一条消息为基础发送给不同的目标
+
+// 复制消息并使用 fn 修改副本
+func (m *Message) With(fn func(m *Message)) *Message {
+	c := m.Clone()
+	fn(c)
+	return c
+}
+
+func (m *Message) WithTitle(title string) *Message {
+	return m.Clone().SetTitle(title)
+}
+
+func (m *Message) WithDescription(description string) *Message {
+	return m.Clone().SetDescription(description)
+}
+
+func (m *Message) WithPayload(payload string) *Message {
+	return m.Clone().SetPayload(payload)
+}
+
+func (m *Message) WithNotifyID(notifyID int64) *Message {
+	return m.Clone().SetNotifyID(notifyID)
+}
+
+func (m *Message) WithCollapseKey(key string) *Message {
+	return m.Clone().SetCollapseKey(key)
+}
+
+func (m *Message) WithTimeToLive(ttl int64) *Message {
+	return m.Clone().SetTimeToLive(ttl)
+}
+
+func (m *Message) WithTimeToSend(timeToSend int64) *Message {
+	return m.Clone().SetTimeToSend(timeToSend)
+}
+
+func (m *Message) WithExtra(key string, value string) *Message {
+	return m.Clone().AddExtra(key, value)
+}
+
+func (m *Message) WithoutExtra(key string) *Message {
+	return m.Clone().RemoveExtra(key)
 }
 
 func (m *Message) SetRestrictedPackageName(packageName ...string) *Message {
@@ -70,7 +149,18 @@ func (m *Message) SetNotifyType(notifyType int32) *Message {
 	return m
 }
 
+// 严格模式下, ttl 不合法时记录错误
 func (m *Message) SetTimeToLive(ttl int64) *Message {
+	if m.strict {
+		m.TimeToLive = ttl
+		if ttl <= 0 || ttl > MaxTimeToLive {
+			m.setFieldError("time_to_live", fmt.Errorf("%d should between 1 and %d", ttl, MaxTimeToLive))
+		} else {
+			m.clearFieldError("time_to_live")
+		}
+		return m
+	}
+
 	if ttl <= 0 || ttl > MaxTimeToLive {
 		m.TimeToLive = MaxTimeToLive
 	} else {
@@ -79,7 +169,20 @@ func (m *Message) SetTimeToLive(ttl int64) *Message {
 	return m
 }
 
+// 严格模式下, timeToSend 不合法时记录错误
 func (m *Message) SetTimeToSend(timeToSend int64) *Message {
+	if m.strict {
+		m.TimeToSend = timeToSend
+		if err := validateTimeToSend(timeToSend); err != nil {
+			m.setFieldError("time_to_send", err)
+		} else if timeToSend <= 0 {
+			m.setFieldError("time_to_send", fmt.Errorf("%d should after (now)", timeToSend))
+		} else {
+			m.clearFieldError("time_to_send")
+		}
+		return m
+	}
+
 	sc := time.Unix(0, timeToSend*int64(time.Millisecond))
 	max := time.Now().Add(MaxTimeToSend)
 	if sc.After(max) {
@@ -97,6 +200,33 @@ func (m *Message) SetNotifyID(notifyID int64) *Message {
 	return m
 }
 
+// 由 key 生成固定的 notifyId, 相同 key 的消息会替换通知栏中的同一条通知
+//
+// 使用 CollapseKeyNamespace 作为命名空间, 如 "chat:123"
+func (m *Message) SetCollapseKey(key string) *Message {
+	return m.SetNamespacedCollapseKey(CollapseKeyNamespace, key)
+}
+
+// 由 namespace 和 key 生成固定的 notifyId
+func (m *Message) SetNamespacedCollapseKey(namespace string, key string) *Message {
+	m.NotifyID = CollapseNotifyID(namespace, key)
+	return m
+}
+
+// namespace 和 key 对应的 notifyId, 取值范围为 1 ~ 2^31-1
+func CollapseNotifyID(namespace string, key string) int64 {
+	h := fnv.New32a()
+	h.Write([]byte(namespace))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+
+	id := int64(h.Sum32() & 0x7fffffff)
+	if id == 0 {
+		id = 1
+	}
+	return id
+}
+
 func (m *Message) AddExtra(key string, value string) *Message {
 	if m.Extra == nil {
 		m.Extra = make(map[string]string)
@@ -127,6 +257,10 @@ func (m *Message) SetBadge(badge int64) *Message {
 	return m
 }
 
+func newJobKey() (string, error) {
+	return randomID()
+}
+
 func (m *Message) SetJobKey(jobKey string) *Message {
 	m.AddExtra("jobkey", jobKey)
 	return m
@@ -183,6 +317,9 @@ const (
 	TargetRegId   TargetType = 1
 	TargetAlias   TargetType = 2
 	TargetAccount TargetType = 3
+	TargetTopic   TargetType = 4
+	TargetTopics  TargetType = 5
+	TargetAll     TargetType = 6
 )
 
 type TargetedMessage struct {
@@ -198,3 +335,41 @@ func NewTargetedMessage(message *Message, target string, targetType TargetType)
 		targetType: targetType,
 	}
 }
+
+func (t *TargetedMessage) Message() *Message {
+	return t.message
+}
+
+func (t *TargetedMessage) Target() string {
+	return t.target
+}
+
+func (t *TargetedMessage) TargetType() TargetType {
+	return t.targetType
+}
+
+type targetedMessageJSON struct {
+	Target     string     `json:"target"`
+	TargetType TargetType `json:"target_type"`
+	Message    *Message   `json:"message"`
+}
+
+func (t TargetedMessage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&targetedMessageJSON{
+		Target:     t.target,
+		TargetType: t.targetType,
+		Message:    t.message,
+	})
+}
+
+func (t *TargetedMessage) UnmarshalJSON(data []byte) error {
+	var v targetedMessageJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	t.target = v.Target
+	t.targetType = v.TargetType
+	t.message = v.Message
+	return nil
+}
@@ -0,0 +1,62 @@
+package xmpush
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMessage_SetCollapseKey(t *testing.T) {
+	a := NewMessage("title", "description").SetCollapseKey("chat:123")
+	b := NewMessage("title", "description").SetCollapseKey("chat:123")
+	c := NewMessage("title", "description").SetNamespacedCollapseKey("other", "chat:123")
+
+	if a.NotifyID <= 0 || a.NotifyID != b.NotifyID {
+		t.Fatal(a.NotifyID, b.NotifyID)
+	}
+
+	if a.NotifyID == c.NotifyID {
+		t.Fatal("namespace should change notifyId")
+	}
+}
+
+func TestMessage_Clone(t *testing.T) {
+	base := NewMessage("title", "description").SetBadge(1)
+
+	c := base.Clone()
+	c.AddExtra("badge", "2")
+
+	if base.Extra["badge"] != "1" {
+		t.Fatal("clone should not share extra")
+	}
+
+	w := base.WithTitle("new title").WithExtra("ticker", "ticker")
+	if base.Title != "title" || base.Extra["ticker"] != "" {
+		t.Fatal("With should not modify base")
+	}
+
+	if w.Title != "new title" || w.Extra["ticker"] != "ticker" || w.Extra["badge"] != "1" {
+		t.Fatal(w)
+	}
+}
+
+func TestTargetedMessage_JSON(t *testing.T) {
+	messages := []TargetedMessage{
+		*NewTargetedMessage(NewMessage("title", "description").SetBadge(1), "alias_1", TargetAlias),
+	}
+
+	data, err := json.Marshal(messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded []TargetedMessage
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	m := decoded[0]
+	if m.Target() != "alias_1" || m.TargetType() != TargetAlias ||
+		m.Message().Title != "title" || m.Message().Extra["badge"] != "1" {
+		t.Fatal(string(data))
+	}
+}
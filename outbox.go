@@ -0,0 +1,448 @@
+package xmpush
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxPending OutboxStatus = "pending" // 等待发送
+	OutboxDone    OutboxStatus = "done"    // 发送成功
+	OutboxFailed  OutboxStatus = "failed"  // 超过最大重试次数, 或消息检查、内容检查不通过
+)
+
+const (
+	defaultOutboxMaxAttempts  = 10
+	defaultOutboxMinBackoff   = time.Second
+	defaultOutboxMaxBackoff   = 5 * time.Minute
+	defaultOutboxPollInterval = time.Second
+	outboxBatchSize           = 100
+)
+
+// outbox 中的一条发送请求
+type OutboxEntry struct {
+	ID          string       `json:"id"`
+	Message     *Message     `json:"message"`
+	Target      Target       `json:"target"`
+	Status      OutboxStatus `json:"status"`
+	Attempts    int          `json:"attempts"`
+	NextAttempt time.Time    `json:"next_attempt"`
+	LastError   string       `json:"last_error,omitempty"`
+	MessageID   string       `json:"message_id,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// outbox 的持久化存储
+type OutboxStore interface {
+	// 保存 entry, 已存在时覆盖
+	Save(entry *OutboxEntry) error
+	// 获取 entry, 不存在时返回 nil
+	Get(id string) (*OutboxEntry, error)
+	// 按 NextAttempt 顺序返回最多 limit 条 NextAttempt 不晚于 now 的 pending entry
+	Due(now time.Time, limit int) ([]*OutboxEntry, error)
+}
+
+// 创建 outbox, 发送请求先写入 store 再通过 client 发送,
+// 进程退出后未完成的请求会在下次启动时继续发送 (至少发送一次)
+func NewOutbox(client *Client, store OutboxStore) (*Outbox, error) {
+	if client == nil || store == nil {
+		return nil, errors.New("error params")
+	}
+	return &Outbox{
+		client:       client,
+		store:        store,
+		maxAttempts:  defaultOutboxMaxAttempts,
+		minBackoff:   defaultOutboxMinBackoff,
+		maxBackoff:   defaultOutboxMaxBackoff,
+		pollInterval: defaultOutboxPollInterval,
+		log:          &nopeLogger{},
+		wakeup:       make(chan struct{}, 1),
+	}, nil
+}
+
+type Outbox struct {
+	client       *Client
+	store        OutboxStore
+	maxAttempts  int
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	pollInterval time.Duration
+	log          logger
+
+	mu         sync.Mutex
+	dispatchMu sync.Mutex // Dispatch 和后台发送不能同时进行, 避免重复发送
+	wakeup     chan struct{}
+	stop       chan struct{}
+	stopped    chan struct{}
+}
+
+func (o *Outbox) SetLogger(logger logger) {
+	o.log = logger
+}
+
+// 最大发送次数, 超过后标记为 OutboxFailed
+func (o *Outbox) SetMaxAttempts(attempts int) {
+	if attempts > 0 {
+		o.maxAttempts = attempts
+	}
+}
+
+// 失败重试的间隔, 从 min 开始每次翻倍, 最大为 max
+func (o *Outbox) SetBackoff(min, max time.Duration) {
+	if min > 0 && max >= min {
+		o.minBackoff = min
+		o.maxBackoff = max
+	}
+}
+
+// 检查到期请求的间隔
+func (o *Outbox) SetPollInterval(interval time.Duration) {
+	if interval > 0 {
+		o.pollInterval = interval
+	}
+}
+
+// 写入发送请求, 返回 entry id
+//
+// 写入前通过 Client.CheckSend 检查消息和目标, 不合法的请求直接返回错误
+func (o *Outbox) Enqueue(message *Message, target Target) (string, error) {
+	if err := o.client.CheckSend(message, target); err != nil {
+		return "", err
+	}
+
+	id, err := randomID()
+	if err != nil {
+		return "", err
+	}
+
+	// 重新发送时使用相同的 jobKey
+	message = message.Clone()
+	if o.client.idempotent && message.Extra["jobkey"] == "" {
+		jobKey, err := newJobKey()
+		if err != nil {
+			return "", err
+		}
+		message.SetJobKey(jobKey)
+	}
+
+	now := time.Now()
+	entry := &OutboxEntry{
+		ID:          id,
+		Message:     message,
+		Target:      target,
+		Status:      OutboxPending,
+		NextAttempt: now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := o.store.Save(entry); err != nil {
+		return "", err
+	}
+
+	select {
+	case o.wakeup <- struct{}{}:
+	default:
+	}
+
+	return id, nil
+}
+
+// 获取 entry 的当前状态
+func (o *Outbox) Get(id string) (*OutboxEntry, error) {
+	return o.store.Get(id)
+}
+
+// 发送所有到期的请求, 返回处理的数量
+func (o *Outbox) Dispatch() (int, error) {
+	o.dispatchMu.Lock()
+	defer o.dispatchMu.Unlock()
+
+	count := 0
+	for {
+		entries, err := o.store.Due(time.Now(), outboxBatchSize)
+		if err != nil {
+			return count, err
+		}
+
+		for _, entry := range entries {
+			if err := o.dispatch(entry); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if len(entries) < outboxBatchSize {
+			return count, nil
+		}
+	}
+}
+
+func (o *Outbox) dispatch(entry *OutboxEntry) error {
+	entry.Attempts++
+
+	result, err := o.client.Send(entry.Message, entry.Target)
+	if err == nil {
+		err = apiError(&result.Result)
+	}
+
+	now := time.Now()
+	entry.UpdatedAt = now
+
+	if err == nil {
+		entry.Status = OutboxDone
+		entry.MessageID = result.Data.ID
+		entry.LastError = ""
+	} else {
+		o.log.Debugf("outbox entry %s attempt %d error: %v", entry.ID, entry.Attempts, err)
+
+		entry.LastError = err.Error()
+		if entry.Attempts >= o.maxAttempts || !retryable(err) {
+			entry.Status = OutboxFailed
+		} else {
+			entry.NextAttempt = now.Add(o.backoff(entry.Attempts))
+		}
+	}
+
+	return o.store.Save(entry)
+}
+
+// 消息检查和内容检查的错误重试也不会成功
+func retryable(err error) bool {
+	switch err.(type) {
+	case *ValidationError, *ComplianceError:
+		return false
+	default:
+		return true
+	}
+}
+
+func (o *Outbox) backoff(attempts int) time.Duration {
+	d := o.minBackoff
+	for i := 1; i < attempts; i++ {
+		d *= 2
+		if d >= o.maxBackoff {
+			return o.maxBackoff
+		}
+	}
+	return d
+}
+
+// 启动后台发送, 直到 Close
+func (o *Outbox) Start() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.stop != nil {
+		return
+	}
+	o.stop = make(chan struct{})
+	o.stopped = make(chan struct{})
+
+	go o.run(o.stop, o.stopped)
+}
+
+func (o *Outbox) run(stop, stopped chan struct{}) {
+	defer close(stopped)
+
+	ticker := time.NewTicker(o.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := o.Dispatch(); err != nil {
+			o.log.Debug("outbox dispatch error: ", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-o.wakeup:
+		}
+	}
+}
+
+// 停止后台发送, 等待正在进行的发送完成
+func (o *Outbox) Close() {
+	o.mu.Lock()
+	stop, stopped := o.stop, o.stopped
+	o.stop, o.stopped = nil, nil
+	o.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-stopped
+	}
+}
+
+// 基于本地文件的 OutboxStore, 每个 entry 一个 json 文件, 按状态分目录存放
+func NewFileOutboxStore(dir string) (*FileOutboxStore, error) {
+	s := &FileOutboxStore{dir: dir}
+	for _, status := range []OutboxStatus{OutboxPending, OutboxDone, OutboxFailed} {
+		if err := os.MkdirAll(s.statusDir(status), 0755); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+type FileOutboxStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func (s *FileOutboxStore) statusDir(status OutboxStatus) string {
+	return filepath.Join(s.dir, string(status))
+}
+
+func (s *FileOutboxStore) path(status OutboxStatus, id string) string {
+	return filepath.Join(s.statusDir(status), id+".json")
+}
+
+func (s *FileOutboxStore) Save(entry *OutboxEntry) error {
+	if err := validateOutboxID(entry.ID); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := writeFileSync(s.path(entry.Status, entry.ID), data); err != nil {
+		return err
+	}
+
+	for _, status := range []OutboxStatus{OutboxPending, OutboxDone, OutboxFailed} {
+		if status == entry.Status {
+			continue
+		}
+		err := os.Remove(s.path(status, entry.ID))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *FileOutboxStore) Get(id string) (*OutboxEntry, error) {
+	if err := validateOutboxID(id); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, status := range []OutboxStatus{OutboxPending, OutboxDone, OutboxFailed} {
+		entry, err := readOutboxEntry(s.path(status, id))
+		if os.IsNotExist(err) {
+			continue
+		}
+		return entry, err
+	}
+
+	return nil, nil
+}
+
+func (s *FileOutboxStore) Due(now time.Time, limit int) ([]*OutboxEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	files, err := ioutil.ReadDir(s.statusDir(OutboxPending))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*OutboxEntry
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+
+		entry, err := readOutboxEntry(filepath.Join(s.statusDir(OutboxPending), file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		if !entry.NextAttempt.After(now) {
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].NextAttempt.Before(entries[j].NextAttempt)
+	})
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return entries, nil
+}
+
+func readOutboxEntry(path string) (*OutboxEntry, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry OutboxEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("outbox entry %s: %v", filepath.Base(path), err)
+	}
+	return &entry, nil
+}
+
+// 先写临时文件再 rename, 保证文件内容完整
+func writeFileSync(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func validateOutboxID(id string) error {
+	if id == "" || filepath.Base(id) != id || strings.HasPrefix(id, ".") {
+		return fmt.Errorf("invalid outbox id %q", id)
+	}
+	return nil
+}
+
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,153 @@
+package xmpush
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestOutboxStore(t *testing.T) (*FileOutboxStore, func()) {
+	dir, err := ioutil.TempDir("", "xmpush-outbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewFileOutboxStore(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return store, func() { os.RemoveAll(dir) }
+}
+
+func TestFileOutboxStore(t *testing.T) {
+	store, cleanup := newTestOutboxStore(t)
+	defer cleanup()
+
+	now := time.Now()
+	entries := []*OutboxEntry{
+		{ID: "b", Status: OutboxPending, NextAttempt: now.Add(-time.Minute)},
+		{ID: "a", Status: OutboxPending, NextAttempt: now.Add(-2 * time.Minute)},
+		{ID: "c", Status: OutboxPending, NextAttempt: now.Add(time.Hour)},
+	}
+	for _, entry := range entries {
+		if err := store.Save(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	due, err := store.Due(now, 10)
+	if err != nil || len(due) != 2 || due[0].ID != "a" || due[1].ID != "b" {
+		t.Fatal(due, err)
+	}
+	if due, _ := store.Due(now, 1); len(due) != 1 || due[0].ID != "a" {
+		t.Fatal(due)
+	}
+
+	entries[1].Status = OutboxDone
+	if err := store.Save(entries[1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(store.path(OutboxPending, "a")); !os.IsNotExist(err) {
+		t.Fatal("pending file should be removed", err)
+	}
+	if due, _ := store.Due(now, 10); len(due) != 1 || due[0].ID != "b" {
+		t.Fatal(due)
+	}
+
+	entries[1].Status = OutboxFailed
+	if err := store.Save(entries[1]); err != nil {
+		t.Fatal(err)
+	}
+	if entry, err := store.Get("a"); err != nil || entry.Status != OutboxFailed {
+		t.Fatal(entry, err)
+	}
+	if entry, err := store.Get("c"); err != nil || entry.Status != OutboxPending {
+		t.Fatal(entry, err)
+	}
+	if entry, err := store.Get("unknown"); err != nil || entry != nil {
+		t.Fatal(entry, err)
+	}
+	if _, err := store.Get("../a"); err == nil {
+		t.Fatal("expect invalid id error")
+	}
+}
+
+func TestOutbox_Dispatch(t *testing.T) {
+	store, cleanup := newTestOutboxStore(t)
+	defer cleanup()
+
+	var posts int32
+	var status int32 = 200
+	c := newStubClient(t, func(req *http.Request, form url.Values) string {
+		atomic.AddInt32(&posts, 1)
+		time.Sleep(10 * time.Millisecond)
+		if atomic.LoadInt32(&status) != 200 {
+			return `{"code":10017,"description":"server busy"}`
+		}
+		return `{"code":0,"data":{"id":"msg_1"}}`
+	})
+
+	outbox, err := NewOutbox(c, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbox.SetBackoff(time.Hour, time.Hour)
+
+	if _, err := outbox.Enqueue(NewMessage("", "description"), RegIdTarget("r1")); err == nil {
+		t.Fatal("expect validation error")
+	}
+	if _, err := outbox.Enqueue(NewMessage("title", "description"), RegIdTarget()); err == nil {
+		t.Fatal("expect target error")
+	}
+
+	id, err := outbox.Enqueue(NewMessage("outbox", "description"), RegIdTarget("r1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 同时调用 Dispatch 只会发送一次
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := outbox.Dispatch(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	entry, err := outbox.Get(id)
+	if err != nil || entry.Status != OutboxDone || entry.MessageID != "msg_1" || posts != 1 {
+		t.Fatal(entry, err, posts)
+	}
+
+	// 服务端错误, 等待重试
+	atomic.StoreInt32(&status, 500)
+	id, _ = outbox.Enqueue(NewMessage("outbox", "description"), RegIdTarget("r1"))
+	if _, err := outbox.Dispatch(); err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ := outbox.Get(id); entry.Status != OutboxPending || entry.Attempts != 1 || !entry.NextAttempt.After(time.Now()) {
+		t.Fatal(entry)
+	}
+
+	// 内容检查不通过, 不重试
+	id, _ = outbox.Enqueue(NewMessage("outbox", "description"), RegIdTarget("r1"))
+	c.SetContentChecker(ContentCheckerFunc(func(m *Message) error {
+		return &ComplianceError{Rule: RuleQuietHours, Field: "send_time", Reason: "night"}
+	}))
+	if _, err := outbox.Dispatch(); err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ := outbox.Get(id); entry.Status != OutboxFailed || entry.Attempts != 1 {
+		t.Fatal(entry)
+	}
+}
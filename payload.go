@@ -0,0 +1,172 @@
+package xmpush
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// payload 最大字节数 (UTF-8 编码)
+var MaxPayloadSize = 4096
+
+// 透传消息 payload 的编解码, 客户端需要使用相同的格式解码
+type PayloadCodec interface {
+	Encode(v interface{}) (string, error)
+	Decode(payload string, v interface{}) error
+}
+
+// protobuf 消息, 与 gogo/protobuf 生成的 Marshal, Unmarshal 方法一致
+//
+// google.golang.org/protobuf 生成的消息没有这两个方法, 需要使用 NewProtobufBase64PayloadCodec
+type ProtoMessage interface {
+	Marshal() ([]byte, error)
+	Unmarshal(data []byte) error
+}
+
+var (
+	// json 字符串
+	JSONPayloadCodec PayloadCodec = jsonPayloadCodec{}
+
+	// protobuf 二进制的标准 base64 编码, 值需要实现 ProtoMessage
+	ProtobufBase64PayloadCodec = NewProtobufBase64PayloadCodec(marshalProtoMessage, unmarshalProtoMessage)
+
+	// json 经 gzip 压缩后的标准 base64 编码
+	GzipBase64PayloadCodec PayloadCodec = gzipBase64PayloadCodec{}
+)
+
+// 使用 codec 编码 v 并设置为 payload
+func (m *Message) SetPayloadWithCodec(codec PayloadCodec, v interface{}) error {
+	payload, err := codec.Encode(v)
+	if err != nil {
+		return err
+	}
+	m.Payload = payload
+	return nil
+}
+
+// 将 v 编码为 json 并设置为 payload
+func (m *Message) SetPayloadJSON(v interface{}) error {
+	return m.SetPayloadWithCodec(JSONPayloadCodec, v)
+}
+
+// 使用 codec 解码 payload
+func DecodePayload(codec PayloadCodec, payload string, v interface{}) error {
+	return codec.Decode(payload, v)
+}
+
+func validatePayloadSize(payload string) error {
+	if len(payload) > MaxPayloadSize {
+		return fmt.Errorf("payload too large (%d bytes), should not more than %d", len(payload), MaxPayloadSize)
+	}
+	return nil
+}
+
+type jsonPayloadCodec struct{}
+
+func (jsonPayloadCodec) Encode(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (jsonPayloadCodec) Decode(payload string, v interface{}) error {
+	return json.Unmarshal([]byte(payload), v)
+}
+
+// 使用 marshal, unmarshal 编解码 protobuf 二进制, 再进行标准 base64 编码,
+// 用于 google.golang.org/protobuf 等没有实现 ProtoMessage 的消息
+//
+//	codec := xmpush.NewProtobufBase64PayloadCodec(
+//		func(v interface{}) ([]byte, error) { return proto.Marshal(v.(proto.Message)) },
+//		func(data []byte, v interface{}) error { return proto.Unmarshal(data, v.(proto.Message)) },
+//	)
+func NewProtobufBase64PayloadCodec(marshal func(v interface{}) ([]byte, error),
+	unmarshal func(data []byte, v interface{}) error) PayloadCodec {
+	return &protobufBase64PayloadCodec{marshal: marshal, unmarshal: unmarshal}
+}
+
+type protobufBase64PayloadCodec struct {
+	marshal   func(v interface{}) ([]byte, error)
+	unmarshal func(data []byte, v interface{}) error
+}
+
+func (c *protobufBase64PayloadCodec) Encode(v interface{}) (string, error) {
+	data, err := c.marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+func (c *protobufBase64PayloadCodec) Decode(payload string, v interface{}) error {
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return err
+	}
+	return c.unmarshal(data, v)
+}
+
+func marshalProtoMessage(v interface{}) ([]byte, error) {
+	pb, ok := v.(ProtoMessage)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a ProtoMessage", v)
+	}
+	return pb.Marshal()
+}
+
+func unmarshalProtoMessage(data []byte, v interface{}) error {
+	pb, ok := v.(ProtoMessage)
+	if !ok {
+		return fmt.Errorf("%T is not a ProtoMessage", v)
+	}
+	return pb.Unmarshal(data)
+}
+
+type gzipBase64PayloadCodec struct{}
+
+func (gzipBase64PayloadCodec) Encode(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func (gzipBase64PayloadCodec) Decode(payload string, v interface{}) error {
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return err
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	data, err = ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if len(data) == 0 {
+		return errors.New("empty gzip payload")
+	}
+	return json.Unmarshal(data, v)
+}
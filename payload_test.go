@@ -0,0 +1,96 @@
+package xmpush
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPayloadCodec(t *testing.T) {
+	type payload struct {
+		ID   int64  `json:"id"`
+		Text string `json:"text"`
+	}
+
+	for _, codec := range []PayloadCodec{JSONPayloadCodec, GzipBase64PayloadCodec} {
+		m := NewMessage("payload", "description")
+		if err := m.SetPayloadWithCodec(codec, &payload{ID: 1, Text: "你好"}); err != nil {
+			t.Fatal(err)
+		}
+
+		var p payload
+		if err := DecodePayload(codec, m.Payload, &p); err != nil {
+			t.Fatal(err)
+		}
+
+		if p.ID != 1 || p.Text != "你好" {
+			t.Fatal(p)
+		}
+	}
+}
+
+func TestClient_ValidatePayloadSize(t *testing.T) {
+	m := NewMessage("payload", "description").SetPayload(strings.Repeat("a", MaxPayloadSize+1))
+	if _, err := client.validateMessage(m); err == nil {
+		t.Fatal("payload too large should error")
+	}
+}
+
+// 只有 string 类型 field 1 的 protobuf 消息, 与 gogo/protobuf 生成的方法一致
+type testProto struct {
+	Text string
+}
+
+func (p *testProto) Marshal() ([]byte, error) {
+	if len(p.Text) > 127 {
+		return nil, errors.New("text too long")
+	}
+	return append([]byte{0x0a, byte(len(p.Text))}, p.Text...), nil
+}
+
+func (p *testProto) Unmarshal(data []byte) error {
+	if len(data) < 2 || data[0] != 0x0a || int(data[1]) != len(data)-2 {
+		return errors.New("invalid testProto")
+	}
+	p.Text = string(data[2:])
+	return nil
+}
+
+func TestProtobufBase64PayloadCodec(t *testing.T) {
+	payload, err := ProtobufBase64PayloadCodec.Encode(&testProto{Text: "hello"})
+	if err != nil || payload != "CgVoZWxsbw==" {
+		t.Fatal(payload, err)
+	}
+
+	var decoded testProto
+	if err := ProtobufBase64PayloadCodec.Decode(payload, &decoded); err != nil || decoded.Text != "hello" {
+		t.Fatal(decoded, err)
+	}
+
+	if _, err := ProtobufBase64PayloadCodec.Encode(struct{}{}); err == nil {
+		t.Fatal("expect not ProtoMessage error")
+	}
+
+	// 不实现 ProtoMessage 的消息通过函数编解码
+	type plain struct{ Text string }
+	codec := NewProtobufBase64PayloadCodec(func(v interface{}) ([]byte, error) {
+		return (&testProto{Text: v.(*plain).Text}).Marshal()
+	}, func(data []byte, v interface{}) error {
+		var p testProto
+		if err := p.Unmarshal(data); err != nil {
+			return err
+		}
+		v.(*plain).Text = p.Text
+		return nil
+	})
+
+	m := NewMessage("title", "description")
+	if err := m.SetPayloadWithCodec(codec, &plain{Text: "hello"}); err != nil || m.Payload != payload {
+		t.Fatal(m.Payload, err)
+	}
+
+	var p plain
+	if err := DecodePayload(codec, m.Payload, &p); err != nil || p.Text != "hello" {
+		t.Fatal(p, err)
+	}
+}
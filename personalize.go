@@ -0,0 +1,155 @@
+package xmpush
+
+import (
+	"errors"
+	"fmt"
+	"text/template"
+)
+
+// SendTargetedMessage 每次最多发送的消息数
+const maxTargetedMessages = 100
+
+// 个性化批量消息, 以 base 为模板为每个目标生成不同的消息
+//
+// base 的标题、描述、payload 和 extra 的值均为 text/template 模板,
+// targetType 只支持 TargetRegId, TargetAlias, TargetAccount
+func NewPersonalizedBatch(base *Message, targetType TargetType) *PersonalizedBatch {
+	return &PersonalizedBatch{
+		base:       base,
+		targetType: targetType,
+	}
+}
+
+type PersonalizedBatch struct {
+	base       *Message
+	targetType TargetType
+	targets    []personalizedTarget
+}
+
+type personalizedTarget struct {
+	target string
+	vars   interface{}
+}
+
+// 添加目标和对应的模板变量
+func (b *PersonalizedBatch) Add(target string, vars interface{}) *PersonalizedBatch {
+	b.targets = append(b.targets, personalizedTarget{target: target, vars: vars})
+	return b
+}
+
+func (b *PersonalizedBatch) Len() int {
+	return len(b.targets)
+}
+
+type personalizedTemplate struct {
+	title       *template.Template
+	description *template.Template
+	payload     *template.Template
+	extra       map[string]*template.Template
+}
+
+func (b *PersonalizedBatch) parse() (*personalizedTemplate, error) {
+	var t personalizedTemplate
+	var err error
+
+	if t.title, err = parseTemplate("title", b.base.Title); err != nil {
+		return nil, err
+	}
+	if t.description, err = parseTemplate("description", b.base.Description); err != nil {
+		return nil, err
+	}
+	if t.payload, err = parseTemplate("payload", b.base.Payload); err != nil {
+		return nil, err
+	}
+
+	t.extra = make(map[string]*template.Template, len(b.base.Extra))
+	for k, v := range b.base.Extra {
+		if t.extra[k], err = parseTemplate("extra."+k, v); err != nil {
+			return nil, err
+		}
+	}
+
+	return &t, nil
+}
+
+// 为每个目标生成消息
+func (b *PersonalizedBatch) Render() ([]TargetedMessage, error) {
+	if b.base == nil {
+		return nil, errors.New("base message can't nil")
+	}
+
+	if b.targetType != TargetRegId &&
+		b.targetType != TargetAlias &&
+		b.targetType != TargetAccount {
+		return nil, fmt.Errorf("unknown target type %d", b.targetType)
+	}
+
+	t, err := b.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]TargetedMessage, 0, len(b.targets))
+	for _, target := range b.targets {
+		message, err := t.render(b.base, target.vars)
+		if err != nil {
+			return nil, fmt.Errorf("target %s: %v", target.target, err)
+		}
+		messages = append(messages, *NewTargetedMessage(message, target.target, b.targetType))
+	}
+
+	return messages, nil
+}
+
+func (t *personalizedTemplate) render(base *Message, vars interface{}) (*Message, error) {
+	message := base.Clone()
+
+	var err error
+	if message.Title, err = executeTemplate(t.title, vars); err != nil {
+		return nil, err
+	}
+	if message.Description, err = executeTemplate(t.description, vars); err != nil {
+		return nil, err
+	}
+	if message.Payload, err = executeTemplate(t.payload, vars); err != nil {
+		return nil, err
+	}
+	for k, extra := range t.extra {
+		if message.Extra[k], err = executeTemplate(extra, vars); err != nil {
+			return nil, err
+		}
+	}
+
+	return message, nil
+}
+
+// 发送个性化批量消息, 每 100 条调用一次 SendTargetedMessage
+//
+// 发生错误时停止发送, 返回已完成的结果和错误
+func (c *Client) SendPersonalized(batch *PersonalizedBatch) ([]*SendResult, error) {
+	if batch == nil || batch.Len() == 0 {
+		return nil, errors.New("batch can't empty")
+	}
+
+	messages, err := batch.Render()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*SendResult
+	for start := 0; start < len(messages); start += maxTargetedMessages {
+		end := start + maxTargetedMessages
+		if end > len(messages) {
+			end = len(messages)
+		}
+
+		chunk := messages[start:end]
+		result, err := c.SendTargetedMessage(&chunk)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,50 @@
+package xmpush
+
+import (
+	"testing"
+)
+
+func TestPersonalizedBatch_Render(t *testing.T) {
+	base := NewMessage("Hi {{.Name}}", "you have {{.Count}} new messages").
+		SetOpenWebURI("https://example.com/inbox/{{.ID}}")
+
+	batch := NewPersonalizedBatch(base, TargetRegId).
+		Add("regid_1", map[string]interface{}{"Name": "a", "Count": 1, "ID": 1}).
+		Add("regid_2", map[string]interface{}{"Name": "b", "Count": 2, "ID": 2})
+
+	messages, err := batch.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != 2 {
+		t.Fatal(len(messages))
+	}
+
+	m := messages[1].message
+	if m.Title != "Hi b" || m.Description != "you have 2 new messages" || m.Extra["web_uri"] != "https://example.com/inbox/2" {
+		t.Fatal(m)
+	}
+
+	if base.Title != "Hi {{.Name}}" {
+		t.Fatal("base should not be modified")
+	}
+}
+
+func TestClient_SendPersonalized(t *testing.T) {
+	batch := NewPersonalizedBatch(NewMessage("Hi {{.}}", "personalized"), TargetRegId)
+	for _, id := range regId {
+		batch.Add(id, id)
+	}
+
+	results, err := client.SendPersonalized(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, result := range results {
+		if result.Code != 0 {
+			t.Fatal(result.Code, result.Description)
+		}
+	}
+}
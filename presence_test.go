@@ -0,0 +1,48 @@
+package xmpush
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestClient_GetRegIdsPresenceBatches(t *testing.T) {
+	var urlLengths []int
+	c := newStubClient(t, func(req *http.Request, form url.Values) string {
+		urlLengths = append(urlLengths, len(req.URL.String()))
+
+		var data []Presence
+		for i, regId := range strings.Split(form.Get("registration_id"), ",") {
+			data = append(data, Presence{RegId: regId, Presence: i%2 == 0})
+		}
+		body, _ := json.Marshal(map[string]interface{}{"code": 0, "data": data})
+		return string(body)
+	})
+
+	regIds := make([]string, 250)
+	for i := range regIds {
+		// 与真实 regId 长度接近
+		regIds[i] = fmt.Sprintf("%060d", i)
+	}
+
+	result, err := c.GetRegIdsPresence(&regIds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urlLengths) != 3 || len(result.Data) != 250 || result.Data[249].RegId != regIds[249] {
+		t.Fatal(urlLengths, len(result.Data))
+	}
+	for _, n := range urlLengths {
+		if n > 8192 {
+			t.Fatal("url too long", n)
+		}
+	}
+
+	present, err := c.FilterPresentRegIds(regIds)
+	if err != nil || len(present) != 125 {
+		t.Fatal(len(present), err)
+	}
+}
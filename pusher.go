@@ -0,0 +1,180 @@
+package xmpush
+
+import (
+	"errors"
+	"time"
+)
+
+// 与厂商无关的推送通知
+type Notification struct {
+	Title       string
+	Body        string
+	Data        map[string]string // 自定义数据
+	DataOnly    bool              // 只发送数据, 不展示通知 (透传消息)
+	ClickURL    string            // 点击后打开的网页
+	Badge       int64             // 角标数, 0 为不设置
+	CollapseKey string            // 相同 CollapseKey 的通知会替换之前的通知
+	TTL         time.Duration     // 离线保留时间, 0 为厂商默认
+}
+
+// 推送结果
+//
+// 发送中途失败时, 已送达厂商的 token 在 Delivered 中, 未在 Delivered 和 InvalidTokens 中的 token 未送达
+type PushResult struct {
+	MessageIDs    []string // 厂商返回的消息 id
+	Delivered     []string // 已送达厂商的 token, 推送给 topic 时为空
+	InvalidTokens []string // 失效的 token
+}
+
+// 与厂商无关的推送接口, 不同厂商的实现可以互相替换
+type Pusher interface {
+	// 推送给设备 token, tokens 为空时返回错误
+	Send(n *Notification, tokens []string) (*PushResult, error)
+	// 推送给订阅了 topic 的设备
+	SendToTopic(n *Notification, topic string) (*PushResult, error)
+	// 订阅 topic
+	Subscribe(tokens []string, topic string) error
+	// 取消订阅 topic
+	Unsubscribe(tokens []string, topic string) error
+	// 失效的设备 token
+	Feedback() ([]string, error)
+}
+
+// 基于 Client 的 Pusher 实现, token 为 regId
+func NewClientPusher(client *Client) *ClientPusher {
+	return &ClientPusher{client: client}
+}
+
+type ClientPusher struct {
+	client *Client
+}
+
+var _ Pusher = (*ClientPusher)(nil)
+
+// 转换为小米推送消息, Data 编码为 json payload
+func (n *Notification) toMessage() (*Message, error) {
+	message := NewMessage(n.Title, n.Body)
+
+	if len(n.Data) > 0 {
+		if err := message.SetPayloadJSON(n.Data); err != nil {
+			return nil, err
+		}
+	}
+	if n.DataOnly {
+		message.EnablePassThrough()
+	}
+	if n.ClickURL != "" {
+		message.SetOpenWebURI(n.ClickURL)
+	}
+	if n.Badge > 0 {
+		message.SetBadge(n.Badge)
+	}
+	if n.CollapseKey != "" {
+		message.SetCollapseKey(n.CollapseKey)
+	}
+	if n.TTL > 0 {
+		message.SetTimeToLive(int64(n.TTL / time.Millisecond))
+	}
+
+	return message, nil
+}
+
+func (p *ClientPusher) Send(n *Notification, tokens []string) (*PushResult, error) {
+	if len(tokens) == 0 {
+		return nil, errors.New("tokens can't empty")
+	}
+
+	message, err := n.toMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	var result PushResult
+	for start := 0; start < len(tokens); start += maxRegIdsPerSend {
+		end := start + maxRegIdsPerSend
+		if end > len(tokens) {
+			end = len(tokens)
+		}
+
+		batch := tokens[start:end]
+		sent, err := p.client.SendToRegId(message, &batch)
+		if err != nil {
+			return &result, err
+		}
+
+		if err := result.add(sent, batch); err != nil {
+			return &result, err
+		}
+	}
+
+	return &result, nil
+}
+
+func (p *ClientPusher) SendToTopic(n *Notification, topic string) (*PushResult, error) {
+	message, err := n.toMessage()
+	if err != nil {
+		return nil, err
+	}
+
+	sent, err := p.client.SendToTopic(message, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	var result PushResult
+	if err := result.add(sent, nil); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (p *ClientPusher) Subscribe(tokens []string, topic string) error {
+	result, err := p.client.SubscribeForRegId(&tokens, topic, "")
+	if err != nil {
+		return err
+	}
+	return apiError(result)
+}
+
+func (p *ClientPusher) Unsubscribe(tokens []string, topic string) error {
+	result, err := p.client.UnsubscribeForRegId(&tokens, topic, "")
+	if err != nil {
+		return err
+	}
+	return apiError(result)
+}
+
+func (p *ClientPusher) Feedback() ([]string, error) {
+	result, err := p.client.FetchInvalidRegIds()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := apiError(&result.Result); err != nil {
+		return nil, err
+	}
+	return result.Data.List, nil
+}
+
+// 记录一次发送的结果, tokens 中不在失效列表的 token 视为已送达
+func (r *PushResult) add(sent *SendResult, tokens []string) error {
+	if err := apiError(&sent.Result); err != nil {
+		return err
+	}
+
+	if sent.Data.ID != "" {
+		r.MessageIDs = append(r.MessageIDs, sent.Data.ID)
+	}
+
+	bad := make(map[string]bool)
+	for _, target := range sent.BadTargets() {
+		bad[target.Target] = true
+		r.InvalidTokens = append(r.InvalidTokens, target.Target)
+	}
+	for _, token := range tokens {
+		if !bad[token] {
+			r.Delivered = append(r.Delivered, token)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package xmpush
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNotification_toMessage(t *testing.T) {
+	n := &Notification{
+		Title:       "title",
+		Body:        "body",
+		Data:        map[string]string{"id": "1"},
+		ClickURL:    "https://example.com",
+		Badge:       3,
+		CollapseKey: "chat:1",
+		TTL:         time.Hour,
+	}
+
+	m, err := n.toMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Description != "body" || m.Payload != `{"id":"1"}` || m.Extra["web_uri"] != "https://example.com" ||
+		m.Extra["badge"] != "3" || m.NotifyID != CollapseNotifyID(CollapseKeyNamespace, "chat:1") ||
+		m.TimeToLive != int64(time.Hour/time.Millisecond) {
+		t.Fatal(m)
+	}
+
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 只有数据的通知不需要标题和描述
+	data := &Notification{Data: map[string]string{"id": "1"}, DataOnly: true}
+	m, err = data.toMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.PassThrough != 1 || m.Payload != `{"id":"1"}` {
+		t.Fatal(m)
+	}
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	m, _ = (&Notification{DataOnly: true}).toMessage()
+	if err := m.Validate(); err == nil {
+		t.Fatal("expect empty payload error")
+	}
+}
+
+func TestClientPusher_SendEmpty(t *testing.T) {
+	if _, err := NewClientPusher(client).Send(&Notification{Title: "pusher", Body: "body"}, nil); err == nil {
+		t.Fatal("expect empty tokens error")
+	}
+}
+
+func TestClientPusher_Send(t *testing.T) {
+	var pusher Pusher = NewClientPusher(client)
+
+	result, err := pusher.Send(&Notification{Title: "pusher", Body: "body"}, regId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.Debug(result.MessageIDs, result.InvalidTokens)
+}
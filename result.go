@@ -1,5 +1,12 @@
 package xmpush
 
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
 type Result struct {
 	Result      string `json:"result"`
 	TraceId     string `json:"trace_id"`
@@ -9,13 +16,73 @@ type Result struct {
 	Reason      string `json:"reason,omitempty"`
 }
 
+// 返回公共的 Result 部分, 用于不关心具体返回类型时检查 code
+func (r *Result) APIResult() *Result {
+	return r
+}
+
+// result.code 不为 0 时返回对应的 error
+func apiError(result *Result) error {
+	if result.Code == 0 {
+		return nil
+	}
+	return fmt.Errorf("xiaomi push API code %d, %s", result.Code, result.Description)
+}
+
 type SendResult struct {
 	Result
 	Data struct {
-		ID string `json:"id,omitempty"`
+		ID        string     `json:"id,omitempty"`
+		BadRegIds StringList `json:"bad_regids,omitempty"` // 失效的 regId
 	} `json:"data,omitempty"`
 }
 
+// 发送失败的目标
+type BadTarget struct {
+	Target string `json:"target"`
+	Reason string `json:"reason"` // 同一次发送的所有目标共用
+}
+
+// 发送结果中被拒绝的 regId 及原因
+//
+// 小米服务端只返回失效的 regId 列表 (bad_regids), 没有每个 regId 的原因,
+// 所有 BadTarget 的 Reason 均为返回结果的 reason, 服务端没有返回时为空
+func (r *SendResult) BadTargets() []BadTarget {
+	if len(r.Data.BadRegIds) == 0 {
+		return nil
+	}
+
+	targets := make([]BadTarget, 0, len(r.Data.BadRegIds))
+	for _, regId := range r.Data.BadRegIds {
+		targets = append(targets, BadTarget{Target: regId, Reason: r.Reason})
+	}
+	return targets
+}
+
+// 兼容 "a,b" 和 ["a", "b"] 两种格式的字符串列表
+type StringList []string
+
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*l = list
+		return nil
+	}
+
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+
+	*l = nil
+	for _, item := range strings.Split(str, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
 type Stat struct {
 	Date                  string `json:"date"`
 	AliasRecipients       int64  `json:"alias_recipients"`
@@ -27,6 +94,16 @@ type Stat struct {
 	SingleRecipients      int64  `json:"single_recipients"`
 }
 
+func (s *Stat) add(o *Stat) {
+	s.AliasRecipients += o.AliasRecipients
+	s.UserAccountRecipients += o.UserAccountRecipients
+	s.RegIDRecipients += o.RegIDRecipients
+	s.Received += o.Received
+	s.BroadcastRecipients += o.BroadcastRecipients
+	s.Click += o.Click
+	s.SingleRecipients += o.SingleRecipients
+}
+
 type StatsResult struct {
 	Result
 	Data struct {
@@ -81,3 +158,36 @@ type RegIdTopicResult struct {
 		List []string `json:"list,omitempty"`
 	} `json:"data,omitempty"`
 }
+
+type RecallResult struct {
+	Result
+	Data struct {
+		ID string `json:"id,omitempty"`
+	} `json:"data,omitempty"`
+}
+
+// 消息是否撤回成功
+func (r *RecallResult) Recalled() bool {
+	return r.Code == 0
+}
+
+type Presence struct {
+	RegId        string `json:"regid"`
+	Presence     bool   `json:"presence"`       // 是否在线
+	LastPingTime int64  `json:"last_ping_time"` // 最后一次连接时间, 毫秒
+}
+
+// 最后一次连接的时间
+func (p *Presence) LastSeen() time.Time {
+	return time.Unix(0, p.LastPingTime*int64(time.Millisecond))
+}
+
+type PresenceResult struct {
+	Result
+	Data Presence `json:"data,omitempty"`
+}
+
+type BatchPresenceResult struct {
+	Result
+	Data []Presence `json:"data,omitempty"`
+}
@@ -0,0 +1,25 @@
+package xmpush
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSendResult_BadTargets(t *testing.T) {
+	responses := []string{
+		`{"result":"ok","code":0,"data":{"id":"id_1","bad_regids":"regid_1,regid_2"}}`,
+		`{"result":"ok","code":0,"reason":"unregistered","data":{"id":"id_1","bad_regids":["regid_1","regid_2"]}}`,
+	}
+
+	for _, response := range responses {
+		var result SendResult
+		if err := json.Unmarshal([]byte(response), &result); err != nil {
+			t.Fatal(err)
+		}
+
+		badTargets := result.BadTargets()
+		if len(badTargets) != 2 || badTargets[1].Target != "regid_2" || badTargets[0].Reason != result.Reason {
+			t.Fatal(response, badTargets)
+		}
+	}
+}
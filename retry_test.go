@@ -0,0 +1,119 @@
+package xmpush
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type errReader struct{}
+
+func (errReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read timeout")
+}
+
+// 按顺序返回 responses, 记录每个请求的 method 和 path
+type scriptedTransport struct {
+	requests  []string
+	responses []func() (*http.Response, error)
+}
+
+func (s *scriptedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.requests = append(s.requests, req.Method+" "+req.URL.Path)
+	if len(s.responses) == 0 {
+		return nil, errors.New("unexpected request")
+	}
+	next := s.responses[0]
+	s.responses = s.responses[1:]
+	return next()
+}
+
+func respond(status int, body string) func() (*http.Response, error) {
+	return func() (*http.Response, error) {
+		return &http.Response{StatusCode: status, Body: ioutil.NopCloser(strings.NewReader(body))}, nil
+	}
+}
+
+func TestClient_RetryGuard(t *testing.T) {
+	const sent = `{"code":0,"data":{"data":{"id":"msg_sent"}}}`
+	const notFound = `{"code":0,"data":{}}`
+
+	cases := []struct {
+		name      string
+		responses []func() (*http.Response, error)
+		send      func(c *Client) (*SendResult, error)
+		requests  string
+		id        string
+	}{
+		{
+			name: "network error",
+			responses: []func() (*http.Response, error){
+				func() (*http.Response, error) { return nil, errors.New("timeout") },
+				respond(200, sent),
+			},
+			requests: "POST " + regIdURL + ",GET " + messageStatusURL,
+			id:       "msg_sent",
+		},
+		{
+			name: "read body error",
+			responses: []func() (*http.Response, error){
+				func() (*http.Response, error) {
+					return &http.Response{StatusCode: 200, Body: ioutil.NopCloser(errReader{})}, nil
+				},
+				respond(200, sent),
+			},
+			requests: "POST " + regIdURL + ",GET " + messageStatusURL,
+			id:       "msg_sent",
+		},
+		{
+			name: "server error not sent",
+			responses: []func() (*http.Response, error){
+				respond(502, "bad gateway"),
+				respond(200, notFound),
+				respond(200, `{"code":0,"data":{"id":"msg_retry"}}`),
+			},
+			requests: "POST " + regIdURL + ",GET " + messageStatusURL + ",POST " + regIdURL,
+			id:       "msg_retry",
+		},
+		{
+			name: "targeted message",
+			responses: []func() (*http.Response, error){
+				func() (*http.Response, error) { return nil, errors.New("timeout") },
+				respond(200, sent),
+			},
+			send: func(c *Client) (*SendResult, error) {
+				messages := []TargetedMessage{*NewTargetedMessage(NewMessage("title", "description"), "r1", TargetRegId)}
+				return c.SendTargetedMessage(&messages)
+			},
+			requests: "POST " + multiRegIdURL + ",GET " + messageStatusURL,
+			id:       "msg_sent",
+		},
+	}
+
+	for _, tc := range cases {
+		transport := &scriptedTransport{responses: tc.responses}
+		c, err := NewClient("secret", "com.a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.SetIdempotent(true)
+		c.SetHTTPClient(&http.Client{Transport: transport})
+
+		send := tc.send
+		if send == nil {
+			send = func(c *Client) (*SendResult, error) {
+				return c.SendToRegId(NewMessage("title", "description"), &[]string{"r1"})
+			}
+		}
+
+		result, err := send(c)
+		if err != nil {
+			t.Fatal(tc.name, err)
+		}
+		if requests := strings.Join(transport.requests, ","); requests != tc.requests || result.Data.ID != tc.id {
+			t.Fatal(tc.name, requests, result.Data.ID)
+		}
+	}
+}
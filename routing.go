@@ -0,0 +1,224 @@
+package xmpush
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 推送渠道
+const (
+	VendorXiaomi = "xiaomi"
+	VendorFCM    = "fcm"
+)
+
+// 设备信息
+//
+// Vendor 为空时使用 RoutingPusher 的默认渠道。FallbackToken 不为空时,
+// 主渠道发送失败后按 FallbackPolicy 改用 FallbackVendor 发送
+type Device struct {
+	Vendor         string
+	Token          string
+	FallbackVendor string
+	FallbackToken  string
+}
+
+func (d *Device) hasFallback() bool {
+	return d.FallbackToken != "" && d.FallbackVendor != "" && d.FallbackVendor != d.Vendor
+}
+
+// 主渠道失败后的回退策略
+type FallbackPolicy int
+
+const (
+	FallbackNever          FallbackPolicy = iota // 不回退
+	FallbackOnError                              // 请求失败时回退
+	FallbackOnInvalidToken                       // 请求失败或 token 失效时回退
+)
+
+// 按设备信息选择推送渠道, 如小米设备使用 Client, 海外设备使用 FCM
+//
+//	router := xmpush.NewRoutingPusher(xmpush.VendorFCM, map[string]xmpush.Pusher{
+//		xmpush.VendorXiaomi: xmpush.NewClientPusher(client),
+//		xmpush.VendorFCM:    fcm.NewClient("project-id", tokenSource),
+//	})
+//	router.SetFallbackPolicy(xmpush.FallbackOnInvalidToken)
+func NewRoutingPusher(defaultVendor string, pushers map[string]Pusher) *RoutingPusher {
+	r := &RoutingPusher{
+		defaultVendor: defaultVendor,
+		pushers:       make(map[string]Pusher, len(pushers)),
+		policy:        FallbackOnError,
+	}
+	for vendor, p := range pushers {
+		r.pushers[vendor] = p
+	}
+	return r
+}
+
+type RoutingPusher struct {
+	defaultVendor string
+	pushers       map[string]Pusher
+	policy        FallbackPolicy
+}
+
+// 设置回退策略, 默认为 FallbackOnError
+func (r *RoutingPusher) SetFallbackPolicy(policy FallbackPolicy) {
+	r.policy = policy
+}
+
+// 按渠道推送的结果
+type RoutingResult struct {
+	Results  map[string]*PushResult // 以渠道为 key 的推送结果, 包括回退发送的结果
+	Errors   map[string]error       // 以渠道为 key 的请求错误
+	Fallback []Device               // 回退到备用渠道发送的设备
+	Failed   []Device               // 所有渠道均未送达的设备
+}
+
+func (r *RoutingResult) result(vendor string) *PushResult {
+	if r.Results[vendor] == nil {
+		r.Results[vendor] = &PushResult{}
+	}
+	return r.Results[vendor]
+}
+
+// 推送给设备, 每个渠道的设备一次调用 Pusher.Send, 回退发送只进行一次
+//
+// 设备的渠道或备用渠道未注册时, 不发送任何设备直接返回错误。
+// 单个渠道失败不会返回错误, 记录在 RoutingResult 中
+func (r *RoutingPusher) Send(n *Notification, devices []Device) (*RoutingResult, error) {
+	if n == nil || len(devices) == 0 {
+		return nil, errors.New("error params")
+	}
+
+	primary, err := r.group(devices, false)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RoutingResult{
+		Results: make(map[string]*PushResult),
+		Errors:  make(map[string]error),
+	}
+
+	var fallback []Device
+	for _, g := range primary {
+		failed, invalid := r.send(result, n, g)
+		fallback = r.collect(result, fallback, failed, r.policy != FallbackNever)
+		fallback = r.collect(result, fallback, invalid, r.policy == FallbackOnInvalidToken)
+	}
+
+	if len(fallback) == 0 {
+		return result, nil
+	}
+
+	secondary, err := r.group(fallback, true)
+	if err != nil {
+		result.Failed = append(result.Failed, fallback...)
+		return result, err
+	}
+	for _, g := range secondary {
+		result.Fallback = append(result.Fallback, g.devices...)
+		failed, invalid := r.send(result, n, g)
+		result.Failed = append(result.Failed, failed...)
+		result.Failed = append(result.Failed, invalid...)
+	}
+
+	return result, nil
+}
+
+type deviceGroup struct {
+	vendor  string
+	devices []Device
+	tokens  []string
+}
+
+// 按渠道分组, 保持设备的原有顺序
+func (r *RoutingPusher) group(devices []Device, fallback bool) ([]*deviceGroup, error) {
+	var groups []*deviceGroup
+	index := make(map[string]*deviceGroup)
+
+	for _, d := range devices {
+		vendor, token := d.Vendor, d.Token
+		if fallback {
+			vendor, token = d.FallbackVendor, d.FallbackToken
+		}
+		if vendor == "" {
+			vendor = r.defaultVendor
+		}
+		if token == "" {
+			return nil, errors.New("device token can't empty")
+		}
+		if _, ok := r.pushers[vendor]; !ok {
+			return nil, fmt.Errorf("unknown vendor %s", vendor)
+		}
+		// 发送前检查备用渠道, 避免主渠道已发送后才发现无法回退
+		if !fallback && d.hasFallback() {
+			if _, ok := r.pushers[d.FallbackVendor]; !ok {
+				return nil, fmt.Errorf("unknown fallback vendor %s", d.FallbackVendor)
+			}
+		}
+
+		g, ok := index[vendor]
+		if !ok {
+			g = &deviceGroup{vendor: vendor}
+			index[vendor] = g
+			groups = append(groups, g)
+		}
+		g.devices = append(g.devices, d)
+		g.tokens = append(g.tokens, token)
+	}
+
+	return groups, nil
+}
+
+// 未送达的设备有备用渠道且允许回退时加入 fallback, 否则记录为失败
+func (r *RoutingPusher) collect(result *RoutingResult, fallback []Device, devices []Device, allow bool) []Device {
+	for _, d := range devices {
+		if allow && d.hasFallback() {
+			fallback = append(fallback, d)
+		} else {
+			result.Failed = append(result.Failed, d)
+		}
+	}
+	return fallback
+}
+
+// 发送一组设备, 返回请求失败未送达的设备和 token 失效的设备
+//
+// 请求失败时, PushResult.Delivered 中的设备已经送达, 不会被回退重复发送
+func (r *RoutingPusher) send(result *RoutingResult, n *Notification, g *deviceGroup) ([]Device, []Device) {
+	sent, err := r.pushers[g.vendor].Send(n, g.tokens)
+	if sent == nil {
+		sent = &PushResult{}
+	}
+
+	total := result.result(g.vendor)
+	total.MessageIDs = append(total.MessageIDs, sent.MessageIDs...)
+	total.Delivered = append(total.Delivered, sent.Delivered...)
+	total.InvalidTokens = append(total.InvalidTokens, sent.InvalidTokens...)
+
+	if err != nil {
+		result.Errors[g.vendor] = err
+	}
+
+	invalid := stringSet(sent.InvalidTokens)
+	delivered := stringSet(sent.Delivered)
+
+	var failed, invalidDevices []Device
+	for i, token := range g.tokens {
+		switch {
+		case invalid[token]:
+			invalidDevices = append(invalidDevices, g.devices[i])
+		case err != nil && !delivered[token]:
+			failed = append(failed, g.devices[i])
+		}
+	}
+	return failed, invalidDevices
+}
+
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
@@ -0,0 +1,117 @@
+package xmpush
+
+import (
+	"errors"
+	"testing"
+)
+
+type fakePusher struct {
+	Pusher
+	err       error
+	failAfter int // 送达 failAfter 个 token 后返回 err, 0 为直接返回
+	invalid   map[string]bool
+	sent      []string
+}
+
+func (p *fakePusher) Send(n *Notification, tokens []string) (*PushResult, error) {
+	var result PushResult
+	for _, token := range tokens {
+		if p.err != nil && len(result.Delivered) >= p.failAfter {
+			return &result, p.err
+		}
+		if p.invalid[token] {
+			result.InvalidTokens = append(result.InvalidTokens, token)
+			continue
+		}
+		p.sent = append(p.sent, token)
+		result.MessageIDs = append(result.MessageIDs, "id-"+token)
+		result.Delivered = append(result.Delivered, token)
+	}
+	return &result, nil
+}
+
+func TestRoutingPusher_Send(t *testing.T) {
+	xiaomi := &fakePusher{invalid: map[string]bool{"mi-stale": true}}
+	gcm := &fakePusher{}
+	router := NewRoutingPusher(VendorFCM, map[string]Pusher{VendorXiaomi: xiaomi, VendorFCM: gcm})
+
+	devices := []Device{
+		{Vendor: VendorXiaomi, Token: "mi-1"},
+		{Vendor: VendorXiaomi, Token: "mi-stale", FallbackVendor: VendorFCM, FallbackToken: "fcm-2"},
+		{Token: "fcm-3"},
+	}
+
+	result, err := router.Send(&Notification{Title: "title", Body: "body"}, devices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(xiaomi.sent) != 1 || len(gcm.sent) != 1 || gcm.sent[0] != "fcm-3" ||
+		len(result.Failed) != 1 || result.Failed[0].Token != "mi-stale" || len(result.Fallback) != 0 {
+		t.Fatal(xiaomi.sent, gcm.sent, result)
+	}
+
+	router.SetFallbackPolicy(FallbackOnInvalidToken)
+	gcm.sent = nil
+	result, err = router.Send(&Notification{Title: "title", Body: "body"}, devices)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gcm.sent) != 2 || gcm.sent[1] != "fcm-2" || len(result.Failed) != 0 ||
+		len(result.Fallback) != 1 || len(result.Results[VendorFCM].MessageIDs) != 2 {
+		t.Fatal(gcm.sent, result)
+	}
+}
+
+func TestRoutingPusher_SendFallbackOnError(t *testing.T) {
+	xiaomi := &fakePusher{err: errors.New("timeout")}
+	gcm := &fakePusher{}
+	router := NewRoutingPusher(VendorXiaomi, map[string]Pusher{VendorXiaomi: xiaomi, VendorFCM: gcm})
+
+	result, err := router.Send(&Notification{Title: "title", Body: "body"}, []Device{
+		{Token: "mi-1", FallbackVendor: VendorFCM, FallbackToken: "fcm-1"},
+		{Token: "mi-2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Errors[VendorXiaomi] == nil || len(gcm.sent) != 1 || gcm.sent[0] != "fcm-1" ||
+		len(result.Failed) != 1 || result.Failed[0].Token != "mi-2" {
+		t.Fatal(gcm.sent, result)
+	}
+
+	if _, err := router.Send(&Notification{}, []Device{{Vendor: "apns", Token: "x"}}); err == nil {
+		t.Fatal("expect unknown vendor error")
+	}
+
+	gcm.sent = nil
+	result, err = router.Send(&Notification{}, []Device{
+		{Vendor: VendorFCM, Token: "f1"},
+		{Vendor: VendorFCM, Token: "f2", FallbackVendor: "huawei", FallbackToken: "h2"},
+	})
+	if err == nil || result != nil || len(gcm.sent) != 0 {
+		t.Fatal("expect unknown fallback vendor error before sending", result, err, gcm.sent)
+	}
+}
+
+func TestRoutingPusher_SendPartialFailure(t *testing.T) {
+	gcm := &fakePusher{err: errors.New("unavailable"), failAfter: 1}
+	xiaomi := &fakePusher{}
+	router := NewRoutingPusher(VendorFCM, map[string]Pusher{VendorXiaomi: xiaomi, VendorFCM: gcm})
+
+	result, err := router.Send(&Notification{Title: "title", Body: "body"}, []Device{
+		{Token: "f1", FallbackVendor: VendorXiaomi, FallbackToken: "m1"},
+		{Token: "f2", FallbackVendor: VendorXiaomi, FallbackToken: "m2"},
+		{Token: "f3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(gcm.sent) != 1 || gcm.sent[0] != "f1" || len(xiaomi.sent) != 1 || xiaomi.sent[0] != "m2" {
+		t.Fatal(gcm.sent, xiaomi.sent)
+	}
+	if result.Errors[VendorFCM] == nil || len(result.Fallback) != 1 || result.Fallback[0].Token != "f2" ||
+		len(result.Failed) != 1 || result.Failed[0].Token != "f3" {
+		t.Fatal(result)
+	}
+}
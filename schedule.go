@@ -0,0 +1,190 @@
+package xmpush
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 定时任务不存在时小米服务端返回的 code, 其他非 0 的 code 视为查询失败
+var ScheduleJobNotFoundCode int64 = 20301
+
+// 通过 Client.Schedule 创建的定时消息
+type ScheduledJob struct {
+	ID      string    // 消息 id, 即定时任务的 job_id
+	Message *Message  // 发送的消息
+	Target  Target    // 发送目标
+	At      time.Time // 发送时间
+
+	client *Client
+}
+
+// 定时发送消息, at 需要在当前时间之后 MaxTimeToSend 之内
+//
+// 返回的任务会被 client 记录, 可以通过 ScheduledJobs 获取
+func (c *Client) Schedule(message *Message, target Target, at time.Time) (*ScheduledJob, error) {
+	if message == nil {
+		return nil, errors.New("message can't nil")
+	}
+
+	job := &ScheduledJob{
+		Message: message.Clone(),
+		Target:  target,
+		client:  c,
+	}
+
+	if err := job.send(at); err != nil {
+		return nil, err
+	}
+
+	c.schedules.add(job)
+	return job, nil
+}
+
+// 本地记录的所有未到发送时间的定时任务, 按发送时间排序
+func (c *Client) ScheduledJobs() []*ScheduledJob {
+	return c.schedules.pending(time.Now())
+}
+
+func (j *ScheduledJob) send(at time.Time) error {
+	j.Message.TimeToSend = at.UnixNano() / int64(time.Millisecond)
+
+	result, err := j.client.Send(j.Message, j.Target)
+	if err != nil {
+		return err
+	}
+
+	if err := apiError(&result.Result); err != nil {
+		return err
+	}
+
+	j.ID = result.Data.ID
+	j.At = at
+	return nil
+}
+
+// 定时任务在小米服务端是否存在
+//
+// code 为 ScheduleJobNotFoundCode 时返回 false, 鉴权失败等其他错误返回 error
+func (j *ScheduledJob) Exists() (bool, error) {
+	result, err := j.client.ScheduleJobExist(j.ID)
+	if err != nil {
+		return false, err
+	}
+
+	if result.Code == ScheduleJobNotFoundCode {
+		return false, nil
+	}
+	if err := apiError(result); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// 取消定时任务
+func (j *ScheduledJob) Cancel() error {
+	result, err := j.client.ScheduleJobDelete(j.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := apiError(result); err != nil {
+		return err
+	}
+
+	j.client.schedules.remove(j.ID)
+	return nil
+}
+
+// 修改发送时间, 先创建新任务再取消原任务, 成功后 ID 会变化
+//
+// 消息设置了 jobKey 时新任务使用新的 jobKey, 避免被服务端去重。
+// 失败时保留原任务, 取消原任务失败时会删除已创建的新任务
+func (j *ScheduledJob) Reschedule(at time.Time) error {
+	next := &ScheduledJob{
+		Message: j.Message.Clone(),
+		Target:  j.Target,
+		client:  j.client,
+	}
+
+	if next.Message.Extra["jobkey"] != "" {
+		jobKey, err := newJobKey()
+		if err != nil {
+			return err
+		}
+		next.Message.SetJobKey(jobKey)
+	}
+
+	if err := next.send(at); err != nil {
+		return err
+	}
+
+	if err := j.Cancel(); err != nil {
+		if _, deleteErr := j.client.ScheduleJobDelete(next.ID); deleteErr != nil {
+			return fmt.Errorf("%v, delete new job %s: %v", err, next.ID, deleteErr)
+		}
+		return err
+	}
+
+	j.ID = next.ID
+	j.Message = next.Message
+	j.At = next.At
+	j.client.schedules.add(j)
+	return nil
+}
+
+type scheduleRegistry struct {
+	mu   sync.Mutex
+	jobs map[string]*ScheduledJob
+}
+
+func newScheduleRegistry() *scheduleRegistry {
+	return &scheduleRegistry{
+		jobs: make(map[string]*ScheduledJob),
+	}
+}
+
+func (r *scheduleRegistry) add(job *ScheduledJob) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs[job.ID] = job
+}
+
+func (r *scheduleRegistry) remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.jobs, id)
+}
+
+func (r *scheduleRegistry) removeJobKey(jobKey string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, job := range r.jobs {
+		if job.Message.Extra["jobkey"] == jobKey {
+			delete(r.jobs, id)
+		}
+	}
+}
+
+// 返回未到发送时间的任务, 同时移除已经发送的任务
+func (r *scheduleRegistry) pending(now time.Time) []*ScheduledJob {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	jobs := make([]*ScheduledJob, 0, len(r.jobs))
+	for id, job := range r.jobs {
+		if !job.At.After(now) {
+			delete(r.jobs, id)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].At.Before(jobs[j].At)
+	})
+	return jobs
+}
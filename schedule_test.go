@@ -0,0 +1,106 @@
+package xmpush
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type stubScheduler struct {
+	jobs       map[string]bool
+	seq        int
+	failSend   bool
+	failDelete bool
+	jobKeys    []string
+}
+
+func (s *stubScheduler) handle(req *http.Request, form url.Values) string {
+	switch req.URL.Path {
+	case regIdURL:
+		if s.failSend {
+			return `{"code":10017,"description":"send failed"}`
+		}
+		s.seq++
+		id := fmt.Sprintf("job_%d", s.seq)
+		s.jobs[id] = true
+		s.jobKeys = append(s.jobKeys, form.Get("extra.jobkey"))
+		return fmt.Sprintf(`{"code":0,"data":{"id":"%s"}}`, id)
+	case scheduleJobExistURL:
+		if form.Get("job_id") == "auth" {
+			return `{"code":22006,"description":"invalid secret"}`
+		}
+		if s.jobs[form.Get("job_id")] {
+			return `{"code":0}`
+		}
+		return fmt.Sprintf(`{"code":%d,"description":"not found"}`, ScheduleJobNotFoundCode)
+	case scheduleJobDeleteURL:
+		if s.failDelete {
+			return `{"code":10017,"description":"delete failed"}`
+		}
+		delete(s.jobs, form.Get("job_id"))
+		return `{"code":0}`
+	}
+	return `{"code":404}`
+}
+
+func TestScheduledJob_Reschedule(t *testing.T) {
+	stub := &stubScheduler{jobs: make(map[string]bool)}
+	c := newStubClient(t, stub.handle)
+
+	job, err := c.Schedule(NewMessage("title", "description").SetJobKey("key_1"), RegIdTarget("r1"), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exists, err := job.Exists(); err != nil || !exists {
+		t.Fatal(exists, err)
+	}
+
+	// 创建新任务失败, 保留原任务
+	stub.failSend = true
+	if err := job.Reschedule(time.Now().Add(2 * time.Hour)); err == nil {
+		t.Fatal("expect send error")
+	}
+	if job.ID != "job_1" || !stub.jobs["job_1"] || len(c.ScheduledJobs()) != 1 {
+		t.Fatal(job.ID, stub.jobs)
+	}
+
+	// 取消原任务失败, 删除新任务, 保留原任务
+	stub.failSend = false
+	stub.failDelete = true
+	if err := job.Reschedule(time.Now().Add(2 * time.Hour)); err == nil {
+		t.Fatal("expect cancel error")
+	}
+	if job.ID != "job_1" || !stub.jobs["job_1"] || !stub.jobs["job_2"] {
+		t.Fatal(job.ID, stub.jobs)
+	}
+
+	stub.failDelete = false
+	at := time.Now().Add(3 * time.Hour)
+	if err := job.Reschedule(at); err != nil {
+		t.Fatal(err)
+	}
+	if job.ID != "job_3" || stub.jobs["job_1"] || !job.At.Equal(at) {
+		t.Fatal(job.ID, stub.jobs)
+	}
+	if jobs := c.ScheduledJobs(); len(jobs) != 1 || jobs[0].ID != "job_3" {
+		t.Fatal(jobs)
+	}
+	if stub.jobKeys[0] != "key_1" || stub.jobKeys[2] == "key_1" || stub.jobKeys[2] == "" {
+		t.Fatal(stub.jobKeys)
+	}
+
+	if err := job.Cancel(); err != nil {
+		t.Fatal(err)
+	}
+	if exists, err := job.Exists(); err != nil || exists {
+		t.Fatal(exists, err)
+	}
+
+	job.ID = "auth"
+	if _, err := job.Exists(); err == nil {
+		t.Fatal("expect api error")
+	}
+}
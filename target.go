@@ -0,0 +1,33 @@
+package xmpush
+
+// 消息的发送目标
+type Target struct {
+	Type    TargetType `json:"type"`
+	Values  []string   `json:"values,omitempty"`
+	TopicOP TopicOP    `json:"topic_op,omitempty"`
+}
+
+func RegIdTarget(regIds ...string) Target {
+	return Target{Type: TargetRegId, Values: regIds}
+}
+
+func AliasTarget(alias ...string) Target {
+	return Target{Type: TargetAlias, Values: alias}
+}
+
+func AccountTarget(accounts ...string) Target {
+	return Target{Type: TargetAccount, Values: accounts}
+}
+
+func TopicTarget(topic string) Target {
+	return Target{Type: TargetTopic, Values: []string{topic}}
+}
+
+// topicOP 为空时，默认为取并集
+func TopicsTarget(topicOP TopicOP, topics ...string) Target {
+	return Target{Type: TargetTopics, Values: topics, TopicOP: topicOP}
+}
+
+func AllTarget() Target {
+	return Target{Type: TargetAll}
+}
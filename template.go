@@ -0,0 +1,232 @@
+package xmpush
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// 单个语言的消息内容, 均为 text/template 模板
+type LocalizedContent struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Payload     string `json:"payload,omitempty"`
+}
+
+type localizedTemplate struct {
+	title       *template.Template
+	description *template.Template
+	payload     *template.Template
+}
+
+// 多语言消息模板
+//
+// fallback 为找不到对应语言时依次尝试的语言
+func NewTemplateRegistry(fallback ...string) *TemplateRegistry {
+	return &TemplateRegistry{
+		templates: make(map[string]map[string]*localizedTemplate),
+		fallback:  fallback,
+	}
+}
+
+type TemplateRegistry struct {
+	mu        sync.RWMutex
+	templates map[string]map[string]*localizedTemplate
+	fallback  []string
+}
+
+// 注册模板, contents 以语言为 key, 如 zh_CN, en
+//
+// 已存在的模板会被替换
+func (r *TemplateRegistry) Register(id string, contents map[string]LocalizedContent) error {
+	if id == "" || len(contents) == 0 {
+		return errors.New("template id and contents can't empty")
+	}
+
+	locales := make(map[string]*localizedTemplate, len(contents))
+	for locale, content := range contents {
+		t, err := parseLocalizedContent(id+"."+locale, &content)
+		if err != nil {
+			return err
+		}
+		locales[normalizeLocale(locale)] = t
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.templates[id] = locales
+	return nil
+}
+
+func parseLocalizedContent(name string, content *LocalizedContent) (*localizedTemplate, error) {
+	var t localizedTemplate
+	var err error
+
+	if t.title, err = parseTemplate(name+".title", content.Title); err != nil {
+		return nil, err
+	}
+	if t.description, err = parseTemplate(name+".description", content.Description); err != nil {
+		return nil, err
+	}
+	if t.payload, err = parseTemplate(name+".payload", content.Payload); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func parseTemplate(name string, text string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Parse(text)
+}
+
+func executeTemplate(t *template.Template, vars interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// 模板实际使用的语言
+//
+// 依次尝试 locale 本身、去掉最后一段后的语言 (zh_Hant_TW -> zh_Hant -> zh) 和 fallback
+func (r *TemplateRegistry) ResolveLocale(id string, locale string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, resolved, err := r.lookup(id, locale)
+	return resolved, err
+}
+
+func (r *TemplateRegistry) lookup(id string, locale string) (*localizedTemplate, string, error) {
+	locales, ok := r.templates[id]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown template %s", id)
+	}
+
+	for _, candidate := range localeChain(locale, r.fallback) {
+		if t, ok := locales[candidate]; ok {
+			return t, candidate, nil
+		}
+	}
+
+	return nil, "", fmt.Errorf("template %s has no locale for %s", id, locale)
+}
+
+func localeChain(locale string, fallback []string) []string {
+	var chain []string
+	locale = normalizeLocale(locale)
+	for locale != "" {
+		chain = append(chain, locale)
+		i := strings.LastIndex(locale, "_")
+		if i < 0 {
+			break
+		}
+		locale = locale[:i]
+	}
+
+	for _, f := range fallback {
+		chain = append(chain, normalizeLocale(f))
+	}
+	return chain
+}
+
+func normalizeLocale(locale string) string {
+	return strings.Replace(strings.TrimSpace(locale), "-", "_", -1)
+}
+
+// 使用模板生成消息
+func (r *TemplateRegistry) Render(id string, locale string, vars interface{}) (*Message, error) {
+	return r.RenderWith(nil, id, locale, vars)
+}
+
+// 以 base 为基础使用模板生成消息, 模板内容会覆盖 base 的标题、描述和 payload
+//
+// base 不会被修改, 为 nil 时使用 NewMessage 的默认设置
+func (r *TemplateRegistry) RenderWith(base *Message, id string, locale string, vars interface{}) (*Message, error) {
+	r.mu.RLock()
+	t, _, err := r.lookup(id, locale)
+	r.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var message *Message
+	if base == nil {
+		message = NewMessage("", "")
+	} else {
+		message = base.Clone()
+	}
+
+	if message.Title, err = executeTemplate(t.title, vars); err != nil {
+		return nil, err
+	}
+	if message.Description, err = executeTemplate(t.description, vars); err != nil {
+		return nil, err
+	}
+	if payload, err := executeTemplate(t.payload, vars); err != nil {
+		return nil, err
+	} else if payload != "" {
+		message.Payload = payload
+	}
+
+	return message, nil
+}
+
+// 模板消息的接收者
+type LocalizedRecipient struct {
+	RegId  string
+	Locale string
+}
+
+// 按语言分组发送模板消息, 返回以实际使用的语言为 key 的发送结果
+//
+// base 为消息的基础设置, 可以为 nil。每组按 1000 个 regId 分批调用 SendToRegId,
+// 发生错误时停止发送, 返回已完成的结果和错误
+func (c *Client) SendTemplateToRegIds(registry *TemplateRegistry, base *Message, id string, vars interface{},
+	recipients []LocalizedRecipient) (map[string][]*SendResult, error) {
+	if registry == nil || len(recipients) == 0 {
+		return nil, errors.New("error params")
+	}
+
+	var locales []string
+	groups := make(map[string][]string)
+	for _, recipient := range recipients {
+		locale, err := registry.ResolveLocale(id, recipient.Locale)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := groups[locale]; !ok {
+			locales = append(locales, locale)
+		}
+		groups[locale] = append(groups[locale], recipient.RegId)
+	}
+
+	results := make(map[string][]*SendResult, len(groups))
+	for _, locale := range locales {
+		message, err := registry.RenderWith(base, id, locale, vars)
+		if err != nil {
+			return results, err
+		}
+
+		regIds := groups[locale]
+		for start := 0; start < len(regIds); start += maxRegIdsPerSend {
+			end := start + maxRegIdsPerSend
+			if end > len(regIds) {
+				end = len(regIds)
+			}
+
+			batch := regIds[start:end]
+			result, err := c.SendToRegId(message, &batch)
+			if err != nil {
+				return results, fmt.Errorf("locale %s: %v", locale, err)
+			}
+			results[locale] = append(results[locale], result)
+		}
+	}
+
+	return results, nil
+}
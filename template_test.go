@@ -0,0 +1,65 @@
+package xmpush
+
+import (
+	"testing"
+)
+
+func TestTemplateRegistry_Render(t *testing.T) {
+	registry := NewTemplateRegistry("en")
+	err := registry.Register("welcome", map[string]LocalizedContent{
+		"zh_CN": {Title: "你好 {{.Name}}", Description: "欢迎回来"},
+		"zh":    {Title: "您好 {{.Name}}", Description: "欢迎"},
+		"en":    {Title: "Hi {{.Name}}", Description: "Welcome back", Payload: `{"name":"{{.Name}}"}`},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vars := map[string]string{"Name": "xmpush"}
+	cases := map[string]string{
+		"zh_CN": "你好 xmpush",
+		"zh-TW": "您好 xmpush",
+		"fr_FR": "Hi xmpush",
+	}
+
+	for locale, title := range cases {
+		m, err := registry.Render("welcome", locale, vars)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if m.Title != title {
+			t.Fatal(locale, m.Title)
+		}
+	}
+
+	if _, err := registry.Render("welcome", "en", map[string]string{}); err == nil {
+		t.Fatal("missing var should error")
+	}
+}
+
+func TestClient_SendTemplateToRegIds(t *testing.T) {
+	registry := NewTemplateRegistry("en")
+	err := registry.Register("welcome", map[string]LocalizedContent{
+		"zh": {Title: "你好", Description: "欢迎回来"},
+		"en": {Title: "Hi", Description: "Welcome back"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := client.SendTemplateToRegIds(registry, nil, "welcome", nil, []LocalizedRecipient{
+		{RegId: regId[0], Locale: "zh_CN"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for locale, rs := range results {
+		for _, result := range rs {
+			if result.Code != 0 {
+				t.Fatal(locale, result.Code, result.Description)
+			}
+		}
+	}
+}
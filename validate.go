@@ -0,0 +1,196 @@
+package xmpush
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	MaxTitleLength       = 50  // 标题最大长度, 按 UTF-16 计算, 中英文字符为 1, emoji 为 2
+	MaxDescriptionLength = 128 // 描述最大长度, 按 UTF-16 计算, 中英文字符为 1, emoji 为 2
+)
+
+// 消息某个字段的错误
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Field, e.Err)
+}
+
+// 消息检查的所有错误
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// 严格模式下, SetTimeToLive, SetTimeToSend 不再修正不合法的值,
+// 而是记录错误, 在 Validate 时返回
+func (m *Message) EnableStrict() *Message {
+	m.strict = true
+	return m
+}
+
+func (m *Message) DisableStrict() *Message {
+	m.strict = false
+	m.fieldErrors = nil
+	return m
+}
+
+func (m *Message) setFieldError(field string, err error) {
+	if m.fieldErrors == nil {
+		m.fieldErrors = make(map[string]error)
+	}
+	m.fieldErrors[field] = err
+}
+
+func (m *Message) clearFieldError(field string) {
+	delete(m.fieldErrors, field)
+}
+
+// 检查消息, 返回所有不合法的字段, 没有错误时返回 nil, 否则返回 *ValidationError
+//
+// 透传消息不展示通知, 标题和描述可以为空, 但 payload 不能为空
+func (m *Message) Validate() error {
+	v := &validation{recorded: m.fieldErrors}
+
+	passThrough := m.PassThrough == 1
+	v.check("title", validateText(m.Title, MaxTitleLength, !passThrough))
+	v.check("description", validateText(m.Description, MaxDescriptionLength, !passThrough))
+	v.check("payload", validatePayload(m.Payload, passThrough))
+	v.check("notify_type", validateNotifyType(m.NotifyType))
+	v.check("time_to_live", validateTimeToLive(m.TimeToLive))
+	v.check("time_to_send", validateTimeToSend(m.TimeToSend))
+	v.check("extra", validateExtra(m.Extra))
+	v.check("extra.notify_effect", validateNotifyEffect(m.Extra))
+	v.check("extra.web_uri", validateHTTPURL(m.Extra, "web_uri"))
+	v.check("extra.callback", validateHTTPURL(m.Extra, "callback"))
+	v.check("audience", validateAudienceFilter(m))
+
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errors}
+}
+
+type validation struct {
+	recorded map[string]error
+	errors   []*FieldError
+}
+
+// setter 已经记录了错误的字段, 使用记录的错误
+func (v *validation) check(field string, err error) {
+	if recorded, ok := v.recorded[field]; ok {
+		err = recorded
+	}
+	if err != nil {
+		v.errors = append(v.errors, &FieldError{Field: field, Err: err})
+	}
+}
+
+func validateText(text string, max int, required bool) error {
+	if required && strings.TrimSpace(text) == "" {
+		return errors.New("can't empty")
+	}
+
+	if n := textLength(text); n > max {
+		return fmt.Errorf("too long (%d), should not more than %d", n, max)
+	}
+	return nil
+}
+
+func validatePayload(payload string, required bool) error {
+	if required && payload == "" {
+		return errors.New("can't empty for pass through message")
+	}
+	return validatePayloadSize(payload)
+}
+
+func validateNotifyType(notifyType int32) error {
+	if notifyType != -1 &&
+		notifyType != 1 &&
+		notifyType != 2 &&
+		notifyType != 4 {
+		return fmt.Errorf("unknown notifyType %d", notifyType)
+	}
+	return nil
+}
+
+func validateTimeToLive(ttl int64) error {
+	if ttl < 0 || ttl > MaxTimeToLive {
+		return fmt.Errorf("%d should between 0 and %d", ttl, MaxTimeToLive)
+	}
+	return nil
+}
+
+func validateTimeToSend(timeToSend int64) error {
+	if timeToSend == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	sc := time.Unix(0, timeToSend*int64(time.Millisecond))
+	if max := now.Add(MaxTimeToSend); sc.After(max) {
+		return fmt.Errorf("%d should before %v", timeToSend, max.Format(time.RFC3339))
+	}
+
+	if sc.Before(now) {
+		return fmt.Errorf("%d should after (now) %v", timeToSend, now.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func validateExtra(extra map[string]string) error {
+	for k := range extra {
+		if k == "" || strings.ContainsAny(k, " \t\r\n=&") {
+			return fmt.Errorf("invalid key %q", k)
+		}
+	}
+	return nil
+}
+
+func validateNotifyEffect(extra map[string]string) error {
+	switch extra["notify_effect"] {
+	case "", "1":
+	case "2":
+		if extra["intent_uri"] == "" {
+			return errors.New("intent_uri can't empty")
+		}
+	case "3":
+		if extra["web_uri"] == "" {
+			return errors.New("web_uri can't empty")
+		}
+	default:
+		return fmt.Errorf("unknown notify_effect %s", extra["notify_effect"])
+	}
+	return nil
+}
+
+func validateHTTPURL(extra map[string]string, key string) error {
+	value, ok := extra[key]
+	if !ok {
+		return nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s is not a http url", value)
+	}
+	return nil
+}
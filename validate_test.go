@@ -0,0 +1,47 @@
+package xmpush
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessage_Validate(t *testing.T) {
+	m := NewMessage("", strings.Repeat("描", MaxDescriptionLength+1)).
+		EnableStrict().
+		SetNotifyType(3).
+		SetTimeToLive(-1).
+		SetTimeToSend(time.Now().Add(-time.Hour).UnixNano() / int64(time.Millisecond)).
+		SetOpenWebURI("ftp://example.com")
+
+	if m.TimeToLive != -1 {
+		t.Fatal("strict mode should not rewrite ttl", m.TimeToLive)
+	}
+
+	err := m.Validate()
+	if err == nil {
+		t.Fatal("message should be invalid")
+	}
+
+	fields := make(map[string]bool)
+	for _, e := range err.(*ValidationError).Errors {
+		fields[e.Field] = true
+	}
+
+	for _, field := range []string{"title", "description", "notify_type", "time_to_live", "time_to_send", "extra.web_uri"} {
+		if !fields[field] {
+			t.Fatal(field, "should be invalid", err)
+		}
+	}
+
+	m.SetTitle("title").
+		SetDescription("description").
+		SetNotifyType(1).
+		SetTimeToLive(1000).
+		SetTimeToSend(time.Now().Add(time.Hour).UnixNano() / int64(time.Millisecond)).
+		SetOpenWebURI("https://example.com")
+
+	if err := m.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}